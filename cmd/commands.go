@@ -1,11 +1,20 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
+	"text/tabwriter"
 
 	"github.com/justincarter/docker-workbench/machine"
 	"github.com/justincarter/docker-workbench/run"
@@ -21,16 +30,172 @@ var Commands = []cli.Command{
 		Name:   "create",
 		Usage:  "Create a new workbench machine in the current directory",
 		Action: Create,
+		Before: flightCheck,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "rollback",
+				Usage: "Remove the machine if creating it fails, instead of saving progress so create can be resumed",
+			},
+			cli.StringFlag{
+				Name:  "driver",
+				Usage: "docker-machine driver to create the machine with: " + strings.Join(machine.DriverNames, ", ") + " (default: virtualbox)",
+			},
+			cli.StringFlag{
+				Name:  "name",
+				Usage: "Name of the machine (default: the name of the current directory)",
+			},
+			cli.IntFlag{
+				Name:  "cpus",
+				Usage: "Number of CPUs for the machine (default: 2)",
+			},
+			cli.IntFlag{
+				Name:  "memory",
+				Usage: "Memory for the machine in MB (default: 2048)",
+			},
+			cli.IntFlag{
+				Name:  "disk-size",
+				Usage: "Disk size for the machine in MB (default: 60000)",
+			},
+			cli.StringFlag{
+				Name:  "iso",
+				Usage: "URL or path of the boot2docker ISO to create the machine from",
+			},
+		},
 	},
 	{
-		Name:   "up",
-		Usage:  "Start the workbench machine and show details",
-		Action: Up,
+		Name:         "up",
+		Usage:        "Start the workbench machine and show details",
+		ArgsUsage:    "[APP]",
+		Action:       Up,
+		Before:       flightCheck,
+		BashComplete: completeWith(appNames),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "use-context",
+				Usage: "Switch the Docker CLI to the workbench machine's context",
+			},
+		},
 	},
 	{
-		Name:   "proxy",
-		Usage:  "Start a reverse proxy to the app in the current directory",
-		Action: Proxy,
+		Name:         "env",
+		Usage:        "Print the commands that point the shell at the workbench machine and app",
+		ArgsUsage:    "[APP]",
+		Action:       Env,
+		Before:       flightCheck,
+		BashComplete: completeWith(appNames),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "shell",
+				Usage: "Shell to print the commands for: " + strings.Join(workbench.Shells, ", ") + " (default: detected)",
+			},
+			cli.BoolFlag{
+				Name:  "unset, u",
+				Usage: "Print the commands that unset the variables instead",
+			},
+		},
+	},
+	{
+		Name:            "compose",
+		Usage:           "Run docker-compose for the app in the current directory against the workbench machine",
+		ArgsUsage:       "[docker-compose arguments...]",
+		Action:          Compose,
+		Before:          flightCheck,
+		SkipFlagParsing: true,
+	},
+	{
+		Name:            "exec",
+		Usage:           "Run a command from the app directory with the workbench machine's environment",
+		ArgsUsage:       "COMMAND [arguments...]",
+		Action:          Exec,
+		Before:          flightCheck,
+		SkipFlagParsing: true,
+	},
+	{
+		Name:         "ls",
+		Usage:        "List all workbench machines and their apps",
+		ArgsUsage:    "[WORKBENCH...]",
+		Action:       Ls,
+		Before:       toolboxCheck,
+		BashComplete: completeWith(workbenchNames),
+	},
+	{
+		Name:         "status",
+		Usage:        "Show the state of the workbench machine, proxy and app",
+		ArgsUsage:    "[APP]",
+		Action:       Status,
+		Before:       flightCheck,
+		BashComplete: completeWith(appNames),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the status as JSON",
+			},
+		},
+	},
+	{
+		Name:   "provision",
+		Usage:  "Re-apply the workbench configuration to an existing machine",
+		Action: Provision,
+		Before: flightCheck,
+	},
+	{
+		Name:   "resize",
+		Usage:  "Change the CPUs, memory or disk size of the workbench machine",
+		Action: Resize,
+		Before: flightCheck,
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "cpus",
+				Usage: "Number of CPUs for the machine",
+			},
+			cli.IntFlag{
+				Name:  "memory",
+				Usage: "Memory for the machine in MB",
+			},
+			cli.IntFlag{
+				Name:  "disk-size",
+				Usage: "Grow the disk of the machine to this size in MB",
+			},
+		},
+	},
+	{
+		Name:   "destroy",
+		Usage:  "Remove the workbench machine and everything in it",
+		Action: Destroy,
+		Before: flightCheck,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "force, f",
+				Usage: "Do not ask for confirmation",
+			},
+		},
+	},
+	{
+		Name:   "recreate",
+		Usage:  "Destroy the workbench machine and create it again with the same settings",
+		Action: Recreate,
+		Before: flightCheck,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "force, f",
+				Usage: "Do not ask for confirmation",
+			},
+		},
+	},
+	{
+		Name:         "completion",
+		Usage:        "Print the shell completion script for bash, zsh, fish or powershell",
+		ArgsUsage:    "SHELL",
+		Action:       Completion,
+		BashComplete: completeWith(completionShells),
+	},
+	{
+		Name:         "proxy",
+		Usage:        "Start a reverse proxy to the app in the current directory, or to every app",
+		ArgsUsage:    "[APP]",
+		Action:       Proxy,
+		Before:       flightCheck,
+		BashComplete: completeWith(appNames),
 		Flags: []cli.Flag{
 			cli.StringFlag{
 				Name:        "port, p",
@@ -38,14 +203,132 @@ var Commands = []cli.Command{
 				Usage:       "Port number to start the proxy on",
 				Destination: &proxyPort,
 			},
+			cli.BoolFlag{
+				Name:  "all",
+				Usage: "Proxy every app in the workbench, routing by the app name at the start of the host name",
+			},
+			cli.BoolFlag{
+				Name:  "tls",
+				Usage: "Also serve HTTPS, with certificates issued by a local CA",
+			},
+			cli.StringFlag{
+				Name:  "tls-port",
+				Value: "8443",
+				Usage: "Port number to serve HTTPS on",
+			},
+			cli.BoolFlag{
+				Name:  "rewrite",
+				Usage: "Rewrite the app's host name on the machine to the proxy host name in redirects, cookies and HTML and JSON responses",
+			},
+			cli.BoolFlag{
+				Name:  "inspect",
+				Usage: "Capture the requests through the proxy and serve a web page for inspecting them on this computer",
+			},
+			cli.StringFlag{
+				Name:  "inspect-port",
+				Value: "4040",
+				Usage: "Port number to serve the inspector on",
+			},
+			cli.IntFlag{
+				Name:  "inspect-history",
+				Value: 200,
+				Usage: "Number of requests the inspector keeps",
+			},
+			cli.IntFlag{
+				Name:  "inspect-body-limit",
+				Value: 256,
+				Usage: "Size in KB of the request and response bodies the inspector keeps",
+			},
+		},
+	},
+	{
+		Name:   "ca",
+		Usage:  "Export the certificate of the local CA used by proxy --tls, for installing on devices",
+		Action: CA,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "out, o",
+				Usage: "File to write the certificate to instead of printing it",
+			},
 		},
 	},
 }
 
-// FlightCheck helper checks for prerequisite commands
-func FlightCheck() error {
+// Flags are the global options available to every command
+var Flags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "Print the docker-machine, docker and VBoxManage commands instead of running them",
+	},
+	cli.BoolFlag{
+		Name:  "verbose",
+		Usage: "Log every external command with its exit code, duration and errors",
+	},
+	cli.StringFlag{
+		Name:  "log-file",
+		Usage: "Append the external command log to a file",
+	},
+	cli.StringFlag{
+		Name:  "log-format",
+		Value: "text",
+		Usage: "Format of the external command log (text or json)",
+	},
+	cli.StringFlag{
+		Name:  "output, o",
+		Value: "text",
+		Usage: "Output format of up, proxy and status (text or json)",
+	},
+}
+
+// Before applies the global options before any command runs
+func Before(c *cli.Context) error {
+	switch c.GlobalString("output") {
+	case "text":
+	case "json":
+		useJSONOutput()
+	default:
+		return fmt.Errorf("docker-workbench: unknown output format '%s'", c.GlobalString("output"))
+	}
+
+	if c.GlobalBool("dry-run") {
+		recorder := run.NewRecorder(os.Stdout)
+		recorder.Reads = run.Default
+		run.Default = recorder
+		workbench.DryRun = true
+	}
+
+	format := c.GlobalString("log-format")
+	if format != "text" && format != "json" {
+		return fmt.Errorf("docker-workbench: unknown log format '%s'", format)
+	}
+	logs := []io.Writer{}
+	if c.GlobalBool("verbose") {
+		logs = append(logs, os.Stderr)
+	}
+	if path := c.GlobalString("log-file"); path != "" {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("docker-workbench: could not open log file: %s", err)
+		}
+		logs = append(logs, f)
+	}
+	if len(logs) > 0 {
+		run.Default = run.NewLogger(run.Default, io.MultiWriter(logs...), format == "json")
+	}
+
+	return nil
+}
+
+// FlightCheck helper checks for prerequisite commands, including those needed by the driver. A nil
+// driver checks only docker and docker-machine, for commands that are not about one workbench.
+func FlightCheck(driver machine.Driver) error {
 
 	toolbox := []string{"docker", "docker-machine", "docker-compose"}
+	if driver == nil {
+		toolbox = []string{"docker", "docker-machine"}
+	} else if _, ok := driver.(machine.Host); ok {
+		toolbox = []string{"docker", "docker-compose"}
+	}
 	missing := []string{}
 	for _, c := range toolbox {
 		if _, err := exec.LookPath(c); err != nil {
@@ -55,13 +338,43 @@ func FlightCheck() error {
 	if len(missing) > 0 {
 		return fmt.Errorf("docker-workbench: %s was not found. Make sure you have installed Docker Toolbox", strings.Join(missing, ", "))
 	}
-	if _, err := exec.LookPath(run.VBoxManagePath()); err != nil {
-		return fmt.Errorf("docker-workbench: VBoxManage was not found. Make sure you have installed VirtualBox")
+	if driver == nil {
+		return nil
+	}
+	for _, tool := range driver.Tools() {
+		if _, err := exec.LookPath(tool.Path); err != nil {
+			return fmt.Errorf("docker-workbench: %s was not found. Make sure you have installed %s", filepath.Base(tool.Path), tool.Install)
+		}
 	}
 
 	return nil
 }
 
+// flightCheck runs FlightCheck before a command for the driver given with --driver, or otherwise
+// the driver of the workbench in the current directory. Outside a workbench only docker and
+// docker-machine are checked, except by create which makes one with the default driver.
+func flightCheck(c *cli.Context) error {
+	if c.IsSet("driver") {
+		driver, err := machine.NewDriver(c.String("driver"))
+		if err != nil {
+			return err
+		}
+		return FlightCheck(driver)
+	}
+	workdir, _ := os.Getwd()
+	driver, ok := workbench.FindDriver(workdir)
+	if !ok && c.Command.Name != "create" {
+		return FlightCheck(nil)
+	}
+	return FlightCheck(driver)
+}
+
+// toolboxCheck runs FlightCheck before a command that covers every workbench, such as ls, leaving
+// the tools of each driver to the command itself
+func toolboxCheck(c *cli.Context) error {
+	return FlightCheck(nil)
+}
+
 // NotFound command
 func NotFound(c *cli.Context, command string) {
 	fmt.Printf("docker-workbench: '%s' is not a docker-workbench command. See 'docker-workbench help'.", command)
@@ -75,81 +388,566 @@ func Version(c *cli.Context) {
 
 // Create command
 func Create(c *cli.Context) error {
+	ctx, stop := interruptContext()
+	defer stop()
 
 	// get name from the current working directory
 	workdir, _ := os.Getwd()
-	name := filepath.Base(workdir)
-
-	m := &machine.Machine{Name: name}
-	if !m.Exists() {
-		m.Create()
-		m.EvalEnv()
+	w, err := workbench.NewWorkbenchAt(workdir)
+	exitOnError(err)
+	exitOnError(applyCreateFlags(c, w))
+	exitOnError(w.Create(ctx, c.Bool("rollback")))
 
-		fmt.Println("Configuring bootsync.sh...")
-		m.SSH("sudo echo 'sudo mkdir -p /workbench && sudo mount -t vboxsf -o uid=1000,gid=50 workbench /workbench' >  /tmp/bootsync.sh")
-		m.SSH("sudo cp /tmp/bootsync.sh /var/lib/boot2docker/bootsync.sh")
-		m.SSH("sudo chmod +x /var/lib/boot2docker/bootsync.sh")
-
-		fmt.Println("Installing Docker Workbench Proxy...")
-		m.SSH("docker run -d --restart=always --name=docker_workbench_proxy -p 80:80 -v '/var/run/docker.sock:/tmp/docker.sock:ro' justincarter/docker-workbench-proxy")
-		m.Stop()
+	return Up(c)
+}
 
-		fmt.Println("Adding /workbench shared folder...")
-		m.ShareFolder(workdir)
+// applyCreateFlags sets the machine name and docker-machine settings given on the command line,
+// which take precedence over .workbench.yml and the shell
+func applyCreateFlags(c *cli.Context, w *workbench.Workbench) error {
+	if c.IsSet("name") {
+		if !machine.ValidName(c.String("name")) {
+			return fmt.Errorf("docker-workbench: '%s' is not a valid machine name. Use letters, numbers, dots and hyphens", c.String("name"))
+		}
+		// one machine per workbench directory, as commands find it by the directory's record
+		record, err := workbench.FindRecord(w.Dir)
+		if err != nil {
+			return err
+		}
+		if record != nil && record.Name != c.String("name") {
+			return fmt.Errorf("docker-workbench: %s already has the workbench machine '%s'. Use docker-workbench recreate to create it again, or docker-workbench destroy before creating '%s'",
+				w.Dir, record.Name, c.String("name"))
+		}
+		w.Name = c.String("name")
+	}
+	if c.IsSet("driver") {
+		driver, err := machine.NewDriver(c.String("driver"))
+		if err != nil {
+			return err
+		}
+		w.SetDriver(driver)
 	}
 
-	return Up(c)
+	vars := w.Driver.Vars()
+	w.Env = map[string]string{}
+	for flag, key := range map[string]string{
+		"cpus":      vars.CPUs,
+		"memory":    vars.Memory,
+		"disk-size": vars.DiskSize,
+		"iso":       vars.ISO,
+	} {
+		if !c.IsSet(flag) {
+			continue
+		}
+		if key == "" {
+			return fmt.Errorf("docker-workbench: --%s cannot be used with the %s driver", flag, w.Driver.Name())
+		}
+		if flag == "iso" {
+			w.Env[key] = c.String(flag)
+		} else {
+			w.Env[key] = strconv.Itoa(c.Int(flag))
+		}
+	}
+	return nil
 }
 
 // Up command
 func Up(c *cli.Context) error {
-	w, err := workbench.NewWorkbench()
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	ctx, stop := interruptContext()
+	defer stop()
+
+	w, err := newWorkbench(ctx, c)
+	exitOnError(err)
+
+	exitOnError(startMachine(ctx, &w.Machine))
+	exitOnError(w.RunHooks(ctx, w.Config.Hooks.PostUp))
+	hints, err := useContext(ctx, &w.Machine, c.Bool("use-context"))
+	exitOnError(err)
+	if w.App != "*" {
+		hints = append(hints, hint{Message: "Start the application:", Command: "docker-compose up"})
 	}
 
-	w.Start()
-	w.PrintEvalHint(true)
+	if !outputJSON {
+		printHints(hints)
+		exitOnError(w.PrintWorkbenchInfo(ctx))
+		return nil
+	}
+	ip, err := w.IP(ctx)
+	exitOnError(err)
+	out := upOutput{
+		Machine: w.Name,
+		IP:      ip,
+		URL:     fmt.Sprintf("http://%s.%s.nip.io/", w.App, ip),
+		Context: w.ContextName(),
+		Hints:   hints,
+	}
 	if w.App != "*" {
-		fmt.Println("\nStart the application:")
-		fmt.Println("docker-compose up")
+		out.App = w.App
 	}
-	w.PrintWorkbenchInfo()
+	return printJSON(out)
+}
+
+// useContext creates or updates the machine's Docker CLI context and switches to it if use is
+// true, otherwise it returns a hint about how to switch. Docker CLIs without contexts fall back to
+// the eval hint.
+func useContext(ctx context.Context, m *machine.Machine, use bool) ([]hint, error) {
+	message := "Run the following command to set this machine as your default:"
+	if err := m.UpdateContext(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if eval := m.EvalHint(true); eval != "" {
+			return []hint{{Message: message, Command: eval}}, nil
+		}
+		return nil, nil
+	}
+	if os.Getenv("DOCKER_MACHINE_NAME") == m.Name {
+		return nil, nil
+	}
+	hints := []hint{}
+	switch {
+	case m.UsingContext(ctx):
+	case use:
+		if err := m.UseContext(ctx); err != nil {
+			return nil, err
+		}
+		hints = append(hints, hint{Message: fmt.Sprintf("The Docker CLI is now using the %s context", m.ContextName())})
+	default:
+		hints = append(hints, hint{Message: message, Command: "docker context use " + m.ContextName()})
+	}
+	if os.Getenv("DOCKER_HOST") != "" {
+		hints = append(hints, hint{Message: "DOCKER_HOST overrides the Docker context, so unset it first:", Command: "eval \"$(docker-machine env -u)\""})
+	}
+	return hints, nil
+}
+
+// Env command
+func Env(c *cli.Context) error {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	shell := c.String("shell")
+	if shell == "" {
+		shell = workbench.DetectShell()
+	}
+
+	// errors go to stderr so that they are not evaluated by the shell
+	var env map[string]string
+	if !c.Bool("unset") {
+		w, err := newWorkbench(ctx, c)
+		exitOnErrorStderr(err)
+		env, err = w.ShellEnv(ctx)
+		exitOnErrorStderr(err)
+	}
+	out, err := workbench.FormatEnv(shell, env)
+	exitOnErrorStderr(err)
+	fmt.Print(out)
+
+	return nil
+}
+
+// Compose command
+func Compose(c *cli.Context) error {
+	return passthrough("docker-compose", c.Args())
+}
+
+// Exec command
+func Exec(c *cli.Context) error {
+	if !c.Args().Present() {
+		return fmt.Errorf("docker-workbench: specify the command to run, e.g. docker-workbench exec docker ps")
+	}
+	return passthrough(c.Args().First(), c.Args().Tail())
+}
+
+// passthrough starts the workbench machine if it is stopped and runs a command from the app
+// directory with the machine's environment, exiting with the exit code of the command
+func passthrough(command string, args []string) error {
+	ctx, stop := interruptContext()
+	w, err := workbench.NewWorkbench(ctx)
+	exitOnError(err)
+	state, err := w.State(ctx)
+	exitOnError(err)
+	if state != "Running" {
+		exitOnError(w.Start(ctx))
+	}
+	stop()
+
+	// the command is passed the signals that would stop docker-workbench, so it is not cancelled
+	err = w.Exec(context.Background(), command, args...)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitStatus(exitErr))
+	}
+	exitOnError(err)
 
 	return nil
 }
 
+// exitStatus returns the exit code of a command, or 128 plus the signal number if a signal
+// stopped it, as shells do
+func exitStatus(err *exec.ExitError) int {
+	if code := err.ExitCode(); code >= 0 {
+		return code
+	}
+	if ws, ok := err.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return 1
+}
+
+// Ls command
+func Ls(c *cli.Context) error {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	summaries, err := workbench.List(ctx, nil)
+	exitOnError(err)
+	if c.Args().Present() {
+		summaries = filterSummaries(summaries, c.Args())
+	}
+	if len(summaries) == 0 {
+		fmt.Println("No workbench machines found. Run docker-workbench create to create one.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTATE\tIP\tDIRECTORY")
+	for _, s := range summaries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Name, s.State, s.IP, s.Dir)
+		for _, a := range s.Apps {
+			fmt.Fprintf(tw, "  %s\t\t\t%s\n", a.Name, a.URL)
+		}
+	}
+	tw.Flush()
+
+	return nil
+}
+
+// filterSummaries returns the summaries of the named workbenches
+func filterSummaries(summaries []workbench.Summary, names []string) []workbench.Summary {
+	filtered := []workbench.Summary{}
+	for _, s := range summaries {
+		for _, name := range names {
+			if s.Name == name {
+				filtered = append(filtered, s)
+			}
+		}
+	}
+	return filtered
+}
+
+// Status command
+func Status(c *cli.Context) error {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	w, err := newWorkbench(ctx, c)
+	exitOnError(err)
+
+	s, err := w.Status(ctx)
+	exitOnError(err)
+
+	if outputJSON || c.Bool("json") {
+		return printJSON(s)
+	}
+
+	fmt.Printf("Machine:     %s (%s)\n", s.Machine, s.State)
+	if s.State != "Running" {
+		return nil
+	}
+	fmt.Printf("IP:          %s\n", s.IP)
+	if s.DockerOK {
+		fmt.Println("Docker:      ok")
+	} else {
+		fmt.Printf("Docker:      %s\n", s.DockerError)
+	}
+	fmt.Printf("Proxy:       %s\n", s.Proxy)
+	if s.Mounted {
+		fmt.Println("/workbench:  mounted")
+	} else {
+		fmt.Println("/workbench:  not mounted")
+	}
+	if s.App == "" {
+		return nil
+	}
+
+	fmt.Printf("App:         %s\n", s.App)
+	if len(s.Containers) == 0 {
+		fmt.Println("  no containers, run docker-compose up")
+	}
+	for _, ct := range s.Containers {
+		fmt.Printf("  %-30s %-10s VIRTUAL_HOST=%s\n", ct.Name, ct.State, ct.VirtualHost)
+	}
+	if s.URL != "" {
+		if s.URLError != "" {
+			fmt.Printf("URL:         %s (%s)\n", s.URL, s.URLError)
+		} else {
+			fmt.Printf("URL:         %s (%d %s)\n", s.URL, s.URLStatus, http.StatusText(s.URLStatus))
+		}
+	}
+
+	return nil
+}
+
+// Provision command
+func Provision(c *cli.Context) error {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	w, err := workbench.NewWorkbench(ctx)
+	exitOnError(err)
+
+	results, err := w.Provision(ctx)
+	exitOnError(err)
+
+	fmt.Printf("\nProvisioned \"%s\":\n", w.Name)
+	for _, r := range results {
+		status := "up to date"
+		if r.Drift != "" {
+			status = r.Drift + " (fixed)"
+		}
+		fmt.Printf("  %-14s%s\n", r.Step, status)
+	}
+
+	return nil
+}
+
+// Resize command
+func Resize(c *cli.Context) error {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	if c.Int("cpus") < 0 || c.Int("memory") < 0 || c.Int("disk-size") < 0 {
+		return fmt.Errorf("docker-workbench: resources must be positive")
+	}
+	if c.Int("cpus") == 0 && c.Int("memory") == 0 && c.Int("disk-size") == 0 {
+		return fmt.Errorf("docker-workbench: specify --cpus, --memory or --disk-size to resize the workbench")
+	}
+
+	w, err := workbench.NewWorkbench(ctx)
+	exitOnError(err)
+
+	exitOnError(w.Resize(ctx, c.Int("cpus"), c.Int("memory"), c.Int("disk-size")))
+	fmt.Printf("Workbench machine '%s' has been resized.\n", w.Name)
+
+	return nil
+}
+
+// Destroy command
+func Destroy(c *cli.Context) error {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	w, err := workbench.NewWorkbench(ctx)
+	exitOnError(err)
+
+	if !c.Bool("force") && !confirmDestroy(ctx, &w.Machine) {
+		return nil
+	}
+	exitOnError(workbench.Destroy(ctx, &w.Machine))
+	fmt.Printf("Workbench machine '%s' has been destroyed.\n", w.Name)
+
+	return nil
+}
+
+// Recreate command
+func Recreate(c *cli.Context) error {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	w, err := workbench.NewWorkbench(ctx)
+	exitOnError(err)
+
+	record, err := workbench.LoadRecord(w.Name)
+	exitOnError(err)
+	if record == nil {
+		fmt.Printf("No record of how '%s' was created was found, so it will be recreated with the current defaults.\n", w.Name)
+	} else {
+		w.Env = record.Env
+	}
+
+	if !c.Bool("force") && !confirmDestroy(ctx, &w.Machine) {
+		return nil
+	}
+	exitOnError(workbench.Destroy(ctx, &w.Machine))
+	exitOnError(w.Create(ctx, false))
+
+	return Up(c)
+}
+
 // Proxy command
 func Proxy(c *cli.Context) error {
-	w, err := workbench.NewWorkbench()
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	ctx, stop := interruptContext()
+	defer stop()
+
+	if c.Bool("all") && c.Args().Present() {
+		return fmt.Errorf("docker-workbench: use either --all or an app name, not both")
 	}
-	if w.App == "*" {
-		fmt.Printf("Could not find the app to proxy for Workbench machine '%s'. Try running from an app directory?\n", w.Name)
+	w, err := newWorkbench(ctx, c)
+	exitOnError(err)
+	if c.Bool("all") {
+		w.App = "*"
+	} else if w.App == "*" {
+		fmt.Printf("Could not find the app to proxy for Workbench machine '%s'. Try running from an app directory, or use --all?\n", w.Name)
 		os.Exit(1)
 	}
 
-	ip, ok := w.IP()
-	if !ok {
-		fmt.Println("Could not find the IP address for this workbench. Have you run docker-workbench up?")
+	ip, err := w.IP(ctx)
+	if err != nil {
+		fmt.Printf("%s. Have you run docker-workbench up?\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Starting reverse proxy on port %s...\n", proxyPort)
+	opts := workbench.ProxyOptions{Port: proxyPort, Rewrite: c.Bool("rewrite")}
+	caCreated := false
+	if c.Bool("tls") {
+		opts.TLSPort = c.String("tls-port")
+		opts.CA, caCreated, err = workbench.LoadCA()
+		exitOnError(err)
+	}
+	inspectURL := ""
+	if c.Bool("inspect") {
+		if c.Int("inspect-history") < 1 || c.Int("inspect-body-limit") < 0 {
+			return fmt.Errorf("docker-workbench: --inspect-history must be at least 1 and --inspect-body-limit cannot be negative")
+		}
+		opts.Inspector = workbench.NewInspector(c.Int("inspect-history"), c.Int("inspect-body-limit")*1024)
+		opts.InspectAddr = "127.0.0.1:" + c.String("inspect-port")
+		inspectURL = fmt.Sprintf("http://localhost:%s/", c.String("inspect-port"))
+	}
+
 	ips, err := w.GetProxyIPs()
+	exitOnError(err)
+	apps := []string{w.App}
+	if w.App == "*" {
+		apps, _ = workbench.FindApps(w.Dir)
+	}
+	urls := []string{}
+	for _, app := range apps {
+		for _, thisip := range ips {
+			urls = append(urls, fmt.Sprintf("http://%s.%s.nip.io:%s/", app, thisip, proxyPort))
+			if opts.CA != nil {
+				urls = append(urls, fmt.Sprintf("https://%s.%s.nip.io:%s/", app, thisip, opts.TLSPort))
+			}
+		}
+	}
+	if outputJSON {
+		out := proxyOutput{
+			Machine:   w.Name,
+			Target:    fmt.Sprintf("http://%s.%s.nip.io/", w.App, ip),
+			Port:      proxyPort,
+			TLSPort:   opts.TLSPort,
+			URLs:      urls,
+			Inspector: inspectURL,
+		}
+		if w.App != "*" {
+			out.App = w.App
+		}
+		exitOnError(printJSON(out))
+	} else {
+		fmt.Printf("Starting reverse proxy on port %s...\n", proxyPort)
+		if opts.CA != nil {
+			fmt.Printf("Serving HTTPS on port %s...\n", opts.TLSPort)
+		}
+		fmt.Printf("Listening on:\n\n")
+		fmt.Println(strings.Join(urls, "\n"))
+		if inspectURL != "" {
+			fmt.Printf("\nInspect the requests through the proxy at %s\n", inspectURL)
+		}
+		if w.App == "*" {
+			fmt.Printf("\nAny other app added to %s can be browsed at http://APP.IP.nip.io:%s/ without restarting\n", w.Dir, proxyPort)
+		}
+		if caCreated {
+			fmt.Println("\nA local CA has been created for HTTPS. Install its certificate on each device that browses the proxy:")
+			fmt.Println("docker-workbench ca --out docker-workbench-ca.crt")
+		}
+		fmt.Println("\nPress Ctrl-C to terminate proxy")
+	}
+	exitOnError(w.StartProxy(ctx, ip, opts))
+
+	return nil
+}
+
+// CA command
+func CA(c *cli.Context) error {
+	ca, created, err := workbench.LoadCA()
+	exitOnError(err)
+	if created {
+		path, _ := workbench.CAPath()
+		fmt.Fprintf(os.Stderr, "Created a local CA in %s\n", filepath.Dir(path))
+	}
+
+	path := c.String("out")
+	if path == "" {
+		fmt.Print(string(ca.PEM))
+		return nil
+	}
+	exitOnError(os.WriteFile(path, ca.PEM, 0644))
+	fmt.Printf("Wrote the CA certificate to %s. Install it on each device as a trusted certificate authority.\n", path)
+	return nil
+}
+
+// newWorkbench resolves the workbench from the current directory, acting on the app named by the
+// command's argument if there is one
+func newWorkbench(ctx context.Context, c *cli.Context) (*workbench.Workbench, error) {
+	w, err := workbench.NewWorkbench(ctx)
+	if err != nil || !c.Args().Present() {
+		return w, err
+	}
+	return w, w.SetApp(c.Args().First())
+}
+
+// startMachine starts the machine unless it is already running
+func startMachine(ctx context.Context, m *machine.Machine) error {
+	state, err := m.State(ctx)
+	if err != nil {
+		return err
+	}
+	if state == "Running" {
+		fmt.Printf("Machine \"%s\" is already running.\n", m.Name)
+		return nil
+	}
+	return m.Start(ctx)
+}
+
+// confirmDestroy lists what will be lost when the machine is destroyed and asks the user to confirm
+func confirmDestroy(ctx context.Context, m *machine.Machine) bool {
+	inv, err := workbench.TakeInventory(ctx, m)
+	exitOnError(err)
+
+	fmt.Printf("Workbench machine '%s' will be destroyed.\n", m.Name)
+	if !inv.Running {
+		fmt.Println("The machine is not running, so its containers and volumes could not be listed.")
+	}
+	if len(inv.Containers) > 0 {
+		fmt.Printf("\nThese running containers will be removed:\n  %s\n", strings.Join(inv.Containers, "\n  "))
+	}
+	if len(inv.Volumes) > 0 {
+		fmt.Printf("\nThese named volumes and their data will be lost:\n  %s\n", strings.Join(inv.Volumes, "\n  "))
+	}
+
+	fmt.Print("\nAre you sure you want to continue? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// interruptContext returns a context that is cancelled when the user presses Ctrl-C, so that any
+// running docker-machine or VBoxManage command is interrupted rather than left running
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
+
+// exitOnError prints the error and exits if a step failed, or if the user pressed Ctrl-C during it.
+// Only the cmd package exits; the machine and workbench packages return errors to their callers.
+func exitOnError(err error) {
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
-	fmt.Printf("Listening on:\n\n")
-	for _, thisip := range ips {
-		fmt.Printf("http://%s.%s.nip.io:%s/\n", w.App, thisip, proxyPort)
-	}
-	fmt.Println("\nPress Ctrl-C to terminate proxy")
-	w.StartProxy(ip, proxyPort)
+}
 
-	return nil
+func exitOnErrorStderr(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
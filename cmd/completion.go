@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/justincarter/docker-workbench/machine"
+	"github.com/justincarter/docker-workbench/workbench"
+	"github.com/urfave/cli"
+)
+
+// completionTimeout is how long dynamic completion waits for VBoxManage, so that pressing tab
+// never hangs the shell
+const completionTimeout = 2 * time.Second
+
+// completionScripts are the scripts that hook the shells up to --generate-bash-completion
+var completionScripts = map[string]string{
+	"bash": `# docker-workbench bash completion
+# Add this to ~/.bashrc: source <(docker-workbench completion bash)
+_docker_workbench() {
+	local cur opts
+	COMPREPLY=()
+	cur="${COMP_WORDS[COMP_CWORD]}"
+	if [[ "$cur" == "-"* ]]; then
+		opts=$("${COMP_WORDS[@]:0:$COMP_CWORD}" "$cur" --generate-bash-completion 2>/dev/null)
+	else
+		opts=$("${COMP_WORDS[@]:0:$COMP_CWORD}" --generate-bash-completion 2>/dev/null)
+	fi
+	COMPREPLY=($(compgen -W "$opts" -- "$cur"))
+}
+complete -o default -F _docker_workbench docker-workbench
+`,
+	"zsh": `#compdef docker-workbench
+# docker-workbench zsh completion
+# Add this to ~/.zshrc: source <(docker-workbench completion zsh)
+_docker_workbench() {
+	local -a opts
+	local cur=${words[-1]}
+	if [[ "$cur" == "-"* ]]; then
+		opts=("${(@f)$(_CLI_ZSH_AUTOCOMPLETE_HACK=1 ${words[@]:0:#words[@]-1} $cur --generate-bash-completion 2>/dev/null)}")
+	else
+		opts=("${(@f)$(_CLI_ZSH_AUTOCOMPLETE_HACK=1 ${words[@]:0:#words[@]-1} --generate-bash-completion 2>/dev/null)}")
+	fi
+	if [[ "${opts[1]}" != "" ]]; then
+		_describe 'values' opts
+	else
+		_files
+	fi
+}
+compdef _docker_workbench docker-workbench
+`,
+	"fish": `# docker-workbench fish completion
+# Add this to ~/.config/fish/config.fish: docker-workbench completion fish | source
+function __fish_docker_workbench_complete
+	set -l args (commandline -opc)
+	set -e args[1]
+	set -l cur (commandline -ct)
+	if string match -q -- '-*' $cur
+		docker-workbench $args $cur --generate-bash-completion 2>/dev/null
+	else
+		docker-workbench $args --generate-bash-completion 2>/dev/null
+	end
+end
+complete -c docker-workbench -f -a '(__fish_docker_workbench_complete)'
+`,
+	"powershell": `# docker-workbench PowerShell completion
+# Add this to $PROFILE: docker-workbench completion powershell | Out-String | Invoke-Expression
+Register-ArgumentCompleter -Native -CommandName docker-workbench -ScriptBlock {
+	param($wordToComplete, $commandAst, $cursorPosition)
+	$words = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() })
+	if ($wordToComplete -ne '') {
+		$words = @($words | Select-Object -SkipLast 1)
+	}
+	if ($wordToComplete -like '-*') {
+		$words += $wordToComplete
+	}
+	& docker-workbench @words --generate-bash-completion 2>$null |
+		Where-Object { $_ -like "$wordToComplete*" } |
+		ForEach-Object { [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_) }
+}
+`,
+}
+
+// Completion command
+func Completion(c *cli.Context) error {
+	shell := c.Args().First()
+	script, ok := completionScripts[shell]
+	if !ok {
+		return fmt.Errorf("docker-workbench: specify the shell to complete for: %s", strings.Join(completionShells(), ", "))
+	}
+	fmt.Print(script)
+	return nil
+}
+
+func completionShells() []string {
+	shells := []string{}
+	for shell := range completionScripts {
+		shells = append(shells, shell)
+	}
+	sort.Strings(shells)
+	return shells
+}
+
+// completeWith returns a BashCompleteFunc that suggests the command's flags when a flag is being
+// completed, and otherwise the values returned by list
+func completeWith(list func() []string) cli.BashCompleteFunc {
+	return func(c *cli.Context) {
+		if len(os.Args) > 2 && strings.HasPrefix(os.Args[len(os.Args)-2], "-") {
+			cmd := c.Command
+			cli.DefaultCompleteWithFlags(&cmd)(c)
+			return
+		}
+		for _, v := range list() {
+			fmt.Fprintln(c.App.Writer, v)
+		}
+	}
+}
+
+// appNames returns the apps of the workbench in the current directory, which may be the workbench
+// directory or one of its apps. Nothing is run, so this is fast enough to complete with.
+func appNames() []string {
+	dir, _ := os.Getwd()
+	apps, _ := workbench.FindApps(dir)
+	if len(apps) == 0 {
+		apps, _ = workbench.FindApps(filepath.Dir(dir))
+	}
+	return apps
+}
+
+// workbenchNames returns the names of the VirtualBox VMs and the machines created with other
+// drivers, without checking that each VM is a workbench
+func workbenchNames() []string {
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+
+	seen := map[string]bool{}
+	machines, _ := machine.List(ctx, nil)
+	for _, m := range machines {
+		seen[m.Name] = true
+	}
+	records, _ := workbench.LoadRecords()
+	for _, r := range records {
+		seen[r.Name] = true
+	}
+	names := []string{}
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// outputJSON is set by --output json, making up, proxy and status print a single JSON document
+var outputJSON bool
+
+// stdout is where the JSON document is written. With --output json, os.Stdout is pointed at
+// stderr so that progress messages and command output cannot get mixed into the document.
+var stdout io.Writer = os.Stdout
+
+// hint is a suggested next step shown once a command has finished
+type hint struct {
+	Message string `json:"message"`
+	Command string `json:"command,omitempty"`
+}
+
+// upOutput is the --output json document for up
+type upOutput struct {
+	Machine string `json:"machine"`
+	App     string `json:"app,omitempty"`
+	IP      string `json:"ip"`
+	URL     string `json:"url"`
+	// Context is the Docker CLI context for the machine
+	Context string `json:"context"`
+	Hints   []hint `json:"hints"`
+}
+
+// proxyOutput is the --output json document for proxy, printed once it is listening
+type proxyOutput struct {
+	Machine string `json:"machine"`
+	// App is empty when every app is proxied
+	App string `json:"app,omitempty"`
+	// Target is the app URL on the machine that requests are proxied to, with * for every app
+	Target string `json:"target"`
+	Port   string `json:"port"`
+	// TLSPort is the HTTPS port, if HTTPS is served
+	TLSPort string   `json:"tls_port,omitempty"`
+	URLs    []string `json:"urls"`
+	// Inspector is the URL of the inspector web page, if requests are being inspected
+	Inspector string `json:"inspector,omitempty"`
+}
+
+// useJSONOutput moves everything written to os.Stdout to stderr, keeping the real stdout for the
+// JSON document
+func useJSONOutput() {
+	outputJSON = true
+	stdout = os.Stdout
+	os.Stdout = os.Stderr
+}
+
+// printJSON writes v to stdout as an indented JSON document
+func printJSON(v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, string(b))
+	return err
+}
+
+// printHints prints each hint as a message followed by its command
+func printHints(hints []hint) {
+	for _, h := range hints {
+		fmt.Printf("\n%s\n", h.Message)
+		if h.Command != "" {
+			fmt.Println(h.Command)
+		}
+	}
+}
@@ -12,11 +12,6 @@ const version = "1.5"
 
 func main() {
 
-	if err := cmd.FlightCheck(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
-	}
-
 	cli.AppHelpTemplate = templateAppHelp
 	cli.CommandHelpTemplate = templateCommandHelp
 	cli.VersionPrinter = cmd.Version
@@ -26,10 +21,14 @@ func main() {
 	app.Version = version
 	app.Usage = "Provision a Docker Workbench for use with docker-machine and docker-compose"
 
+	app.EnableBashCompletion = true
 	app.CommandNotFound = cmd.NotFound
+	app.Flags = cmd.Flags
+	app.Before = cmd.Before
 	app.Commands = cmd.Commands
 
 	if err := app.Run(os.Args); err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 }
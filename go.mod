@@ -2,4 +2,7 @@ module github.com/justincarter/docker-workbench
 
 go 1.16
 
-require github.com/urfave/cli v1.22.5
+require (
+	github.com/urfave/cli v1.22.5
+	gopkg.in/yaml.v2 v2.4.0
+)
@@ -0,0 +1,87 @@
+package machine
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+)
+
+// ContextEndpoint returns the `docker context` --docker endpoint for the machine's Docker engine,
+// using the TLS certificates from the cert path reported by `docker-machine env`
+func ContextEndpoint(env map[string]string) string {
+	endpoint := "host=" + env["DOCKER_HOST"]
+	if env["DOCKER_TLS_VERIFY"] == "1" && env["DOCKER_CERT_PATH"] != "" {
+		for _, f := range []string{"ca", "cert", "key"} {
+			endpoint += "," + f + "=" + filepath.Join(env["DOCKER_CERT_PATH"], f+".pem")
+		}
+	}
+	return endpoint
+}
+
+// UpdateContext creates or updates a Docker CLI context named after the machine that points at
+// its Docker engine, so that docker, docker-compose and other tools can use it without evaluating
+// `docker-machine env`. Machines on the local Docker engine have no context of their own.
+func (m *Machine) UpdateContext(ctx context.Context) error {
+	if _, ok := m.host(); ok {
+		return nil
+	}
+	env, err := m.EnvOutput(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+	action := "update"
+	if !m.hasContext(ctx) {
+		action = "create"
+	}
+	_, err = m.runner().Output(ctx, "docker", "context", action, m.Name,
+		"--description", "docker-workbench machine "+m.Name,
+		"--docker", ContextEndpoint(env))
+	return err
+}
+
+// UseContext makes the machine's Docker CLI context the current context
+func (m *Machine) UseContext(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+	_, err := m.runner().Output(ctx, "docker", "context", "use", m.ContextName())
+	return err
+}
+
+// UsingContext returns true if the machine's Docker CLI context is the current context
+func (m *Machine) UsingContext(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+	out, err := m.runner().Output(ctx, "docker", "context", "show")
+	return err == nil && strings.TrimSpace(string(out)) == m.ContextName()
+}
+
+// RemoveContext removes the machine's Docker CLI context, if it has one
+func (m *Machine) RemoveContext(ctx context.Context) error {
+	if _, ok := m.host(); ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+	if !m.hasContext(ctx) {
+		return nil
+	}
+	_, err := m.runner().Output(ctx, "docker", "context", "rm", "-f", m.Name)
+	return err
+}
+
+// ContextName is the Docker CLI context for the machine, which is the default context for
+// machines on the local Docker engine
+func (m *Machine) ContextName() string {
+	if _, ok := m.host(); ok {
+		return "default"
+	}
+	return m.Name
+}
+
+func (m *Machine) hasContext(ctx context.Context) bool {
+	code, err := m.runner().ExitCode(ctx, "docker", "context", "inspect", m.Name)
+	return err == nil && code == 0
+}
@@ -0,0 +1,110 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Driver creates docker machines with one docker-machine driver, shares host folders into them
+// and reports their IP addresses
+type Driver interface {
+	// Name is the docker-machine driver name
+	Name() string
+	// Tools are the host executables the driver needs, besides docker, docker-machine and docker-compose
+	Tools() []Tool
+	// Vars are the docker-machine environment variables for the machine's resources
+	Vars() Vars
+	// Defaults are the docker-machine environment variables machines are created with by default
+	Defaults() map[string]string
+	// CreateArgs returns extra `docker-machine create` options for the environment in env
+	CreateArgs(env map[string]string) []string
+
+	Exists(ctx context.Context, m *Machine) (bool, error)
+	IP(ctx context.Context, m *Machine) (string, error)
+
+	// ShareFolder shares the host folder path into the machine under name
+	ShareFolder(ctx context.Context, m *Machine, name, path string) error
+	// SharedFolders maps the name of each folder shared into the machine to its host path
+	SharedFolders(ctx context.Context, m *Machine) (map[string]string, error)
+	RemoveSharedFolder(ctx context.Context, m *Machine, name string) error
+	// MountCommand returns the shell command run in the machine to mount a shared folder
+	MountCommand(name, path, mount string) string
+	// BootScript is the path of the script the machine runs at boot to mount the shared folders,
+	// or empty if the mounts are made directly and do not survive a reboot
+	BootScript() string
+	// ShareStopped is true if folders can only be shared while the machine is stopped
+	ShareStopped() bool
+}
+
+// Tool is a host executable needed by a Driver
+type Tool struct {
+	Path string
+	// Install is what to install to get the tool
+	Install string
+}
+
+// Vars are the names of the docker-machine environment variables a driver is configured with
+type Vars struct {
+	// Prefix is shared by every variable of the driver, e.g. VIRTUALBOX_
+	Prefix string
+	// CPUs, Memory (MB), DiskSize (MB) and ISO are empty if the driver cannot set them
+	CPUs     string
+	Memory   string
+	DiskSize string
+	ISO      string
+}
+
+// DriverNames are the names accepted by NewDriver
+var DriverNames = []string{"virtualbox", "kvm2", "generic", "native"}
+
+// NewDriver returns the Driver for a docker-machine driver name, defaulting to VirtualBox
+func NewDriver(name string) (Driver, error) {
+	switch name {
+	case "", "virtualbox":
+		return VirtualBox{}, nil
+	case "kvm2":
+		return KVM{}, nil
+	case "kvm":
+		// docker-machine-driver-kvm is a different plugin to kvm2, with its own options
+		return nil, fmt.Errorf("docker-workbench: the kvm driver is not supported. Use kvm2, which needs docker-machine-driver-kvm2")
+	case "generic":
+		return Generic{}, nil
+	case "native":
+		return Native{}, nil
+	}
+	return nil, fmt.Errorf("docker-workbench: unknown driver '%s'. Use one of %s", name, strings.Join(DriverNames, ", "))
+}
+
+// resourceDefaults returns the default resources of a new machine as environment variables, with
+// the CPUs and memory limited to what this computer has
+func resourceDefaults(v Vars) map[string]string {
+	cpus, memory := 2, 2048
+	if host := HostCPUs(); host < cpus {
+		cpus = host
+	}
+	if host := HostMemory(); host > 0 && host < memory {
+		memory = host
+	}
+	return map[string]string{
+		v.CPUs:     strconv.Itoa(cpus),
+		v.DiskSize: "60000",
+		v.Memory:   strconv.Itoa(memory),
+	}
+}
+
+// dockerMachineIP returns the IP address docker-machine reports for the machine
+func dockerMachineIP(ctx context.Context, m *Machine) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+	out, err := m.output(ctx, "docker-machine", "ip", m.Name)
+	if err != nil {
+		return "", err
+	}
+	ip := strings.Split(string(out), "\n")[0]
+	if !ValidIPv4(ip) {
+		return "", ErrNoIP
+	}
+	return ip, nil
+}
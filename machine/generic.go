@@ -0,0 +1,91 @@
+package machine
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/justincarter/docker-workbench/run"
+)
+
+// genericFolders is where the generic driver records the folders shared into a host
+const genericFolders = "/etc/docker-workbench/folders"
+
+// Generic is the Driver for an existing Linux host reached over SSH, configured with the
+// GENERIC_* variables of the docker-machine generic driver. The host cannot see this computer's
+// files, so shared folders must already exist at the same path on the host (e.g. over NFS) and
+// are bind mounted into place.
+type Generic struct{}
+
+// Name is the docker-machine driver name
+func (Generic) Name() string { return "generic" }
+
+// Tools returns nothing as the generic driver is built into docker-machine
+func (Generic) Tools() []Tool { return nil }
+
+// Vars has only a prefix, as the resources are those of the host
+func (Generic) Vars() Vars { return Vars{Prefix: "GENERIC_"} }
+
+// Defaults returns nothing
+func (Generic) Defaults() map[string]string { return map[string]string{} }
+
+// CreateArgs returns nothing as the generic driver reads its environment variables
+func (Generic) CreateArgs(env map[string]string) []string { return nil }
+
+// Exists returns true if docker-machine knows the host
+func (Generic) Exists(ctx context.Context, m *Machine) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+	out, err := m.runner().Output(ctx, "docker-machine", "ls", "-q")
+	if err != nil {
+		return false, err
+	}
+	for _, name := range strings.Fields(string(out)) {
+		if name == m.Name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// IP returns the IP address docker-machine reports for the host
+func (Generic) IP(ctx context.Context, m *Machine) (string, error) {
+	return dockerMachineIP(ctx, m)
+}
+
+// ShareFolder records the folder on the host
+func (Generic) ShareFolder(ctx context.Context, m *Machine, name, folder string) error {
+	return m.SSH(ctx, "sudo mkdir -p "+genericFolders+" && echo "+run.Quote(folder)+" | sudo tee "+path.Join(genericFolders, name)+" > /dev/null")
+}
+
+// SharedFolders returns the folders recorded on the host
+func (Generic) SharedFolders(ctx context.Context, m *Machine) (map[string]string, error) {
+	out, err := m.SSHOutput(ctx, "grep -Hs '' "+genericFolders+"/* || true")
+	if err != nil {
+		return nil, err
+	}
+	folders := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) == 2 {
+			folders[path.Base(kv[0])] = kv[1]
+		}
+	}
+	return folders, nil
+}
+
+// RemoveSharedFolder forgets the folder on the host
+func (Generic) RemoveSharedFolder(ctx context.Context, m *Machine, name string) error {
+	return m.SSH(ctx, "sudo rm -f "+path.Join(genericFolders, name))
+}
+
+// MountCommand bind mounts the folder from the same path on the host, unless it is already mounted
+func (Generic) MountCommand(name, folder, mount string) string {
+	return "mountpoint -q " + mount + " || (sudo mkdir -p " + mount + " && sudo mount --bind " + run.Quote(folder) + " " + mount + ")"
+}
+
+// BootScript is empty as there is no boot script common to every Linux host
+func (Generic) BootScript() string { return "" }
+
+// ShareStopped is false as docker-machine cannot stop or start a generic host
+func (Generic) ShareStopped() bool { return false }
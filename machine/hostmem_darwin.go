@@ -0,0 +1,17 @@
+package machine
+
+import (
+	"encoding/binary"
+	"syscall"
+)
+
+func hostMemory() int {
+	s, err := syscall.Sysctl("hw.memsize")
+	if err != nil {
+		return 0
+	}
+	// Sysctl returns the raw uint64 with trailing zero bytes trimmed
+	b := make([]byte, 8)
+	copy(b, s)
+	return int(binary.LittleEndian.Uint64(b) / 1024 / 1024)
+}
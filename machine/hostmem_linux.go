@@ -0,0 +1,26 @@
+package machine
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func hostMemory() int {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		// MemTotal:       16318720 kB
+		fields := strings.Fields(s.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, _ := strconv.Atoi(fields[1])
+			return kb / 1024
+		}
+	}
+	return 0
+}
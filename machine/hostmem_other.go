@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package machine
+
+func hostMemory() int {
+	return 0
+}
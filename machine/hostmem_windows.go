@@ -0,0 +1,29 @@
+package machine
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// memoryStatusEx is the MEMORYSTATUSEX struct filled in by GlobalMemoryStatusEx
+type memoryStatusEx struct {
+	Length               uint32
+	MemoryLoad           uint32
+	TotalPhys            uint64
+	AvailPhys            uint64
+	TotalPageFile        uint64
+	AvailPageFile        uint64
+	TotalVirtual         uint64
+	AvailVirtual         uint64
+	AvailExtendedVirtual uint64
+}
+
+func hostMemory() int {
+	status := memoryStatusEx{}
+	status.Length = uint32(unsafe.Sizeof(status))
+	proc := syscall.NewLazyDLL("kernel32.dll").NewProc("GlobalMemoryStatusEx")
+	if ok, _, _ := proc.Call(uintptr(unsafe.Pointer(&status))); ok == 0 {
+		return 0
+	}
+	return int(status.TotalPhys / 1024 / 1024)
+}
@@ -0,0 +1,150 @@
+package machine
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KVMConnect is the libvirt connection URI the kvm2 driver creates domains on
+var KVMConnect = "qemu:///system"
+
+// KVM is the Driver for libvirt KVM domains running boot2docker, created with docker-machine-driver-kvm2.
+// Folders are shared with virtio 9p.
+type KVM struct{}
+
+// Name is the docker-machine driver name
+func (KVM) Name() string { return "kvm2" }
+
+// Tools returns the kvm2 driver plugin and virsh
+func (KVM) Tools() []Tool {
+	return []Tool{
+		{Path: "docker-machine-driver-kvm2", Install: "the docker-machine KVM2 driver"},
+		{Path: "virsh", Install: "libvirt"},
+	}
+}
+
+// Vars is empty, as the kvm2 plugin has no options or variables for its resources
+func (KVM) Vars() Vars { return Vars{} }
+
+// Defaults returns nothing
+func (KVM) Defaults() map[string]string { return map[string]string{} }
+
+// CreateArgs returns nothing. The kvm2 plugin is minikube's, which registers no `docker-machine
+// create` options (its GetCreateFlags returns none), so docker-machine would refuse any --kvm-*
+// option with "flag provided but not defined".
+func (KVM) CreateArgs(env map[string]string) []string { return nil }
+
+// Exists returns true if there is a libvirt domain for the machine
+func (KVM) Exists(ctx context.Context, m *Machine) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+	out, err := m.runner().Output(ctx, "virsh", "--connect", KVMConnect, "list", "--all", "--name")
+	if err != nil {
+		return false, err
+	}
+	for _, name := range strings.Fields(string(out)) {
+		if name == m.Name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// IP returns the IP address docker-machine reports for the domain
+func (KVM) IP(ctx context.Context, m *Machine) (string, error) {
+	return dockerMachineIP(ctx, m)
+}
+
+// ShareFolder adds a 9p filesystem to the stopped domain
+func (KVM) ShareFolder(ctx context.Context, m *Machine, name, path string) error {
+	return virshDevice(ctx, m, "attach-device", name, path)
+}
+
+// SharedFolders returns the 9p filesystems of the domain, mapping each mount tag to its host path
+func (KVM) SharedFolders(ctx context.Context, m *Machine) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+	out, err := m.runner().Output(ctx, "virsh", "--connect", KVMConnect, "dumpxml", "--inactive", m.Name)
+	if err != nil {
+		return nil, err
+	}
+	return parseDomainFilesystems(out)
+}
+
+// RemoveSharedFolder removes a 9p filesystem from the stopped domain
+func (d KVM) RemoveSharedFolder(ctx context.Context, m *Machine, name string) error {
+	folders, err := d.SharedFolders(ctx, m)
+	if err != nil {
+		return err
+	}
+	return virshDevice(ctx, m, "detach-device", name, folders[name])
+}
+
+// MountCommand mounts a 9p filesystem
+func (KVM) MountCommand(name, path, mount string) string {
+	return "sudo mkdir -p " + mount + " && sudo mount -t 9p -o trans=virtio,version=9p2000.L " + name + " " + mount
+}
+
+// BootScript is the boot2docker bootsync.sh
+func (KVM) BootScript() string { return boot2dockerBootScript }
+
+// ShareStopped is true as filesystems are added to the domain configuration used at the next boot
+func (KVM) ShareStopped() bool { return true }
+
+// domainFilesystem is a <filesystem> device in a libvirt domain
+type domainFilesystem struct {
+	XMLName    xml.Name `xml:"filesystem"`
+	Type       string   `xml:"type,attr"`
+	AccessMode string   `xml:"accessmode,attr"`
+	Source     struct {
+		Dir string `xml:"dir,attr"`
+	} `xml:"source"`
+	Target struct {
+		Dir string `xml:"dir,attr"`
+	} `xml:"target"`
+}
+
+// virshDevice attaches or detaches a 9p filesystem device, which virsh reads from a file
+func virshDevice(ctx context.Context, m *Machine, action, name, path string) error {
+	fs := domainFilesystem{Type: "mount", AccessMode: "mapped"}
+	fs.Source.Dir = path
+	fs.Target.Dir = name
+	b, err := xml.Marshal(fs)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp("", "docker-workbench-*.xml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("docker-workbench: could not write %s: %s", f.Name(), err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+	return m.runner().Run(ctx, "virsh", "--connect", KVMConnect, action, m.Name, f.Name(), "--config")
+}
+
+// parseDomainFilesystems parses the 9p filesystems from `virsh dumpxml`
+func parseDomainFilesystems(output []byte) (map[string]string, error) {
+	var domain struct {
+		Filesystems []domainFilesystem `xml:"devices>filesystem"`
+	}
+	if err := xml.Unmarshal(output, &domain); err != nil {
+		return nil, err
+	}
+	folders := make(map[string]string)
+	for _, fs := range domain.Filesystems {
+		folders[fs.Target.Dir] = fs.Source.Dir
+	}
+	return folders, nil
+}
@@ -1,104 +1,366 @@
 package machine
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/justincarter/docker-workbench/run"
 )
 
-// Machine represents a docker machine
+// Timeouts applied to each docker-machine and VBoxManage call made by a Machine
+var (
+	CreateTimeout = 15 * time.Minute
+	StartTimeout  = 5 * time.Minute
+	StopTimeout   = 2 * time.Minute
+	RemoveTimeout = 2 * time.Minute
+	SSHTimeout    = 5 * time.Minute
+	QueryTimeout  = 30 * time.Second
+)
+
+// ErrMachineNotFound is matched by the error returned when a docker machine does not exist
+var ErrMachineNotFound = errors.New("machine not found")
+
+// ErrNoIP is returned when docker-machine does not report a valid IP address for a machine
+var ErrNoIP = errors.New("could not find the IP address for this workbench")
+
+// NotFoundError is returned when a docker machine does not exist
+type NotFoundError struct {
+	Name string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("Workbench machine '%s' not found.", e.Name)
+}
+
+// Is makes NotFoundError match ErrMachineNotFound
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrMachineNotFound
+}
+
+// Machine represents a docker machine. Methods return a *NotFoundError if the machine does not
+// exist, a *run.CommandError if docker-machine or VBoxManage fails, and a *run.TimeoutError if
+// they do not finish in time.
 type Machine struct {
 	Name string
+
+	// Defaults holds docker-machine environment variables (e.g. VIRTUALBOX_MEMORY_SIZE) used by
+	// Create in place of the workbench defaults, which can still be overridden from the shell
+	Defaults map[string]string
+
+	// Env holds docker-machine environment variables used by Create, taking precedence over the
+	// defaults and the shell
+	Env map[string]string
+
+	// Driver creates the machine and shares folders into it, defaulting to VirtualBox when nil
+	Driver Driver
+
+	// Runner executes the docker-machine and VBoxManage commands, defaulting to run.Default when nil
+	Runner run.Runner
 }
 
-// Create the docker machine
-func (m *Machine) Create() {
+// CreateEnv returns the docker-machine environment variables used to create the machine: the
+// driver defaults, overridden by Defaults, then by any of the driver's variables set in the shell
+// (e.g. VIRTUALBOX_*), then by Env
+func (m *Machine) CreateEnv() map[string]string {
+	d := m.driver()
+	env := d.Defaults()
+	for k, v := range m.Defaults {
+		env[k] = v
+	}
+	for _, e := range os.Environ() {
+		kv := strings.SplitN(e, "=", 2)
+		if strings.HasPrefix(kv[0], d.Vars().Prefix) && kv[1] != "" {
+			env[kv[0]] = kv[1]
+		}
+	}
+	for k, v := range m.Env {
+		env[k] = v
+	}
+	return env
+}
 
-	// default configuration using docker-machine environment variables
-	env := map[string]string{
-		"VIRTUALBOX_CPU_COUNT":   "2",
-		"VIRTUALBOX_DISK_SIZE":   "60000",
-		"VIRTUALBOX_MEMORY_SIZE": "2048",
-		"VIRTUALBOX_NO_SHARE":    "true",
+// List returns a Machine for every VirtualBox VM, using r to run commands (run.Default if nil)
+func List(ctx context.Context, r run.Runner) ([]*Machine, error) {
+	m := &Machine{Runner: r}
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+	out, err := m.runner().Output(ctx, run.VBoxManagePath(), "list", "vms")
+	if err != nil {
+		return nil, err
 	}
-	for k, v := range env {
-		// set defaults only if the variable is currently unset
-		if os.Getenv(k) == "" {
-			os.Setenv(k, v)
+	machines := []*Machine{}
+	for _, name := range parseVMList(out) {
+		machines = append(machines, &Machine{Name: name, Runner: r})
+	}
+	return machines, nil
+}
+
+// Create the docker machine
+func (m *Machine) Create(ctx context.Context) error {
+	if h, ok := m.host(); ok {
+		return h.Create(ctx, m)
+	}
+	env := m.CreateEnv()
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		// pass on only the variables that aren't already set in the shell
+		if os.Getenv(k) != env[k] {
+			ctx = run.WithEnv(ctx, k+"="+env[k])
 		}
 	}
 
 	// create the machine
-	args := fmt.Sprintf("create --driver virtualbox %s", m.Name)
-	cmd := exec.Command("docker-machine", strings.Fields(args)...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		fmt.Printf("docker-workbench: docker-machine create failed.")
-		os.Exit(1)
+	ctx, cancel := context.WithTimeout(ctx, CreateTimeout)
+	defer cancel()
+	args := append([]string{"create", "--driver", m.driver().Name()}, m.driver().CreateArgs(env)...)
+	if err := m.runner().Run(ctx, "docker-machine", append(args, m.Name)...); err != nil {
+		return fmt.Errorf("docker-workbench: docker-machine create failed: %w", err)
 	}
+	return nil
+}
 
+// EnvOutput returns the docker environment variables for the machine from `docker-machine env`,
+// which fails if docker-machine cannot connect to the machine's Docker engine
+func (m *Machine) EnvOutput(ctx context.Context) (map[string]string, error) {
+	if h, ok := m.host(); ok {
+		return h.EnvOutput(ctx, m)
+	}
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+	out, err := m.output(ctx, "docker-machine", "env", m.Name, "--shell=bash")
+	if err != nil {
+		return nil, err
+	}
+	return parseEnvOutput(out), nil
 }
 
 // EvalEnv sets docker environment variables
-func (m *Machine) EvalEnv() {
-	out, _ := run.Output("docker-machine", "env", m.Name, "--shell=bash")
-	env := parseEnvOutput(out)
+func (m *Machine) EvalEnv(ctx context.Context) error {
+	env, err := m.EnvOutput(ctx)
+	if err != nil {
+		return err
+	}
 	for k, v := range env {
 		os.Setenv(k, v)
 	}
+	return nil
 }
 
 // PrintEvalHint shows a hint about running docker env if required
 func (m *Machine) PrintEvalHint(checkenv bool) {
-	showhint := true
-	if checkenv == true && os.Getenv("DOCKER_MACHINE_NAME") == m.Name {
-		showhint = false
-	}
-	if showhint == true {
+	if hint := m.EvalHint(checkenv); hint != "" {
 		fmt.Println("\nRun the following command to set this machine as your default:")
-		fmt.Printf("eval \"$(docker-machine env %s)\"\n", m.Name)
+		fmt.Println(hint)
+	}
+}
+
+// EvalHint returns the command that sets the machine as the shell's default, or "" if it is not
+// needed because checkenv is true and the shell already uses the machine
+func (m *Machine) EvalHint(checkenv bool) string {
+	if _, ok := m.host(); ok {
+		return ""
+	}
+	if checkenv == true && os.Getenv("DOCKER_MACHINE_NAME") == m.Name {
+		return ""
 	}
+	return fmt.Sprintf("eval \"$(docker-machine env %s)\"", m.Name)
 }
 
 // Exists checks if a VM exists
-func (m *Machine) Exists() bool {
-	out, _ := run.Output(run.VBoxManagePath(), "list", "vms")
-	re := regexp.MustCompile("(?mi)^\"" + m.Name + "\"")
-	return re.Match(out)
+func (m *Machine) Exists(ctx context.Context) (bool, error) {
+	return m.driver().Exists(ctx, m)
+}
+
+// State returns the docker-machine status of the machine, e.g. Running or Stopped
+func (m *Machine) State(ctx context.Context) (string, error) {
+	if h, ok := m.host(); ok {
+		return h.State(ctx, m)
+	}
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+	out, err := m.output(ctx, "docker-machine", "status", m.Name)
+	return strings.TrimSpace(string(out)), err
 }
 
 // IP returns the IP address of the docker machine
-func (m *Machine) IP() (ip string, success bool) {
-	out, _ := run.Output("docker-machine", "ip", m.Name)
-	ip = strings.Split(string(out), "\n")[0]
-	success = ValidIPv4(ip)
-	return
+func (m *Machine) IP(ctx context.Context) (string, error) {
+	return m.driver().IP(ctx, m)
 }
 
-// ShareFolder adds a /workbench shared folder to the VM
-func (m *Machine) ShareFolder(folder string) {
-	args := []string{"sharedfolder", "add", m.Name, "--name", "workbench", "--hostpath", folder}
-	run.Run(run.VBoxManagePath(), args...)
+// ShareFolder shares folder into the machine as the workbench folder
+func (m *Machine) ShareFolder(ctx context.Context, folder string) error {
+	return m.AddSharedFolder(ctx, "workbench", folder)
+}
+
+// AddSharedFolder shares a host folder into the machine under name
+func (m *Machine) AddSharedFolder(ctx context.Context, name, folder string) error {
+	return m.driver().ShareFolder(ctx, m, name, folder)
 }
 
 // SSH into the docker machine to run a command
-func (m *Machine) SSH(command string) {
-	run.Run("docker-machine", "ssh", m.Name, command)
+func (m *Machine) SSH(ctx context.Context, command string) error {
+	if h, ok := m.host(); ok {
+		return h.Shell(ctx, m, command)
+	}
+	ctx, cancel := context.WithTimeout(ctx, SSHTimeout)
+	defer cancel()
+	return m.runner().Run(ctx, "docker-machine", "ssh", m.Name, command)
+}
+
+// SSHOutput runs a command in the docker machine over SSH and returns its output
+func (m *Machine) SSHOutput(ctx context.Context, command string) ([]byte, error) {
+	if h, ok := m.host(); ok {
+		return h.ShellOutput(ctx, m, command)
+	}
+	ctx, cancel := context.WithTimeout(ctx, SSHTimeout)
+	defer cancel()
+	return m.output(ctx, "docker-machine", "ssh", m.Name, command)
+}
+
+// VMInfo holds the VirtualBox settings and state of a VM, keyed as in `VBoxManage showvminfo --machinereadable`
+type VMInfo map[string]string
+
+// VMInfo returns the VirtualBox settings and state of the VM
+func (m *Machine) VMInfo(ctx context.Context) (VMInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+	out, err := m.runner().Output(ctx, run.VBoxManagePath(), "showvminfo", m.Name, "--machinereadable")
+	if err != nil {
+		return nil, err
+	}
+	return parseMachineReadable(out), nil
+}
+
+// SharedFolders returns the folders shared into the machine, mapping each name to its host path
+func (m *Machine) SharedFolders(ctx context.Context) (map[string]string, error) {
+	return m.driver().SharedFolders(ctx, m)
+}
+
+// RemoveSharedFolder stops sharing a folder into the machine
+func (m *Machine) RemoveSharedFolder(ctx context.Context, name string) error {
+	return m.driver().RemoveSharedFolder(ctx, m, name)
+}
+
+// DriverName returns the name of the docker-machine driver the machine was created with
+func (m *Machine) DriverName(ctx context.Context) (string, error) {
+	if _, ok := m.host(); ok {
+		return m.driver().Name(), nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+	out, err := m.output(ctx, "docker-machine", "inspect", "--format", "{{.DriverName}}", m.Name)
+	return strings.TrimSpace(string(out)), err
+}
+
+// ModifyVM changes the number of CPUs and memory (MB) of the stopped VM, leaving either unchanged if 0
+func (m *Machine) ModifyVM(ctx context.Context, cpus, memory int) error {
+	args := []string{"modifyvm", m.Name}
+	if cpus > 0 {
+		args = append(args, "--cpus", strconv.Itoa(cpus))
+	}
+	if memory > 0 {
+		args = append(args, "--memory", strconv.Itoa(memory))
+	}
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+	return m.runner().Run(ctx, run.VBoxManagePath(), args...)
+}
+
+// DiskSize returns the capacity in MB of a VirtualBox disk image
+func (m *Machine) DiskSize(ctx context.Context, path string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+	out, err := m.runner().Output(ctx, run.VBoxManagePath(), "showmediuminfo", "disk", path)
+	if err != nil {
+		return 0, err
+	}
+	size, ok := parseCapacity(out)
+	if !ok {
+		return 0, fmt.Errorf("docker-workbench: could not find the size of %s", path)
+	}
+	return size, nil
+}
+
+// ResizeDisk grows a VirtualBox disk image of the stopped VM to size MB
+func (m *Machine) ResizeDisk(ctx context.Context, path string, size int) error {
+	ctx, cancel := context.WithTimeout(ctx, StopTimeout)
+	defer cancel()
+	return m.runner().Run(ctx, run.VBoxManagePath(), "modifymedium", "disk", path, "--resize", strconv.Itoa(size))
 }
 
 // Start the docker machine
-func (m *Machine) Start() {
-	run.Run("docker-machine", "start", m.Name)
+func (m *Machine) Start(ctx context.Context) error {
+	if h, ok := m.host(); ok {
+		return h.Start(ctx, m)
+	}
+	ctx, cancel := context.WithTimeout(ctx, StartTimeout)
+	defer cancel()
+	return m.runner().Run(ctx, "docker-machine", "start", m.Name)
 }
 
 // Stop the docker machine
-func (m *Machine) Stop() {
-	run.Run("docker-machine", "stop", m.Name)
+func (m *Machine) Stop(ctx context.Context) error {
+	if h, ok := m.host(); ok {
+		return h.Stop(ctx, m)
+	}
+	ctx, cancel := context.WithTimeout(ctx, StopTimeout)
+	defer cancel()
+	return m.runner().Run(ctx, "docker-machine", "stop", m.Name)
+}
+
+// Remove the docker machine and its VM
+func (m *Machine) Remove(ctx context.Context) error {
+	if h, ok := m.host(); ok {
+		return h.Remove(ctx, m)
+	}
+	ctx, cancel := context.WithTimeout(ctx, RemoveTimeout)
+	defer cancel()
+	return m.runner().Run(ctx, "docker-machine", "rm", "-y", m.Name)
+}
+
+// driver returns the Driver for the machine
+func (m *Machine) driver() Driver {
+	if m.Driver == nil {
+		return VirtualBox{}
+	}
+	return m.Driver
+}
+
+// host returns the driver as a Host if it runs the workbench without docker-machine
+func (m *Machine) host() (Host, bool) {
+	h, ok := m.driver().(Host)
+	return h, ok
+}
+
+// runner returns the Runner for the machine
+func (m *Machine) runner() run.Runner {
+	if m.Runner == nil {
+		return run.Default
+	}
+	return m.Runner
+}
+
+// output runs a docker-machine command and returns its output, converting the error docker-machine
+// gives for a missing host into a NotFoundError
+func (m *Machine) output(ctx context.Context, command string, args ...string) ([]byte, error) {
+	out, _, err := m.runner().OutputStderr(ctx, command, args...)
+	var cmdErr *run.CommandError
+	if errors.As(err, &cmdErr) && bytes.Contains(cmdErr.Stderr, []byte("does not exist")) {
+		return out, &NotFoundError{Name: m.Name}
+	}
+	return out, err
 }
 
 // ValidIPv4 returns true for valid IPv4 addresses
@@ -120,3 +382,56 @@ func parseEnvOutput(output []byte) map[string]string {
 	}
 	return env
 }
+
+// parseMachineReadable parses the key="value" output from `VBoxManage showvminfo --machinereadable`
+func parseMachineReadable(output []byte) VMInfo {
+	info := make(VMInfo)
+	re := regexp.MustCompile(`^"?([^"=]+)"?="?(.*?)"?$`)
+	for _, line := range strings.Split(string(output), "\n") {
+		matches := re.FindStringSubmatch(strings.TrimSpace(line))
+		if len(matches) == 3 {
+			info[matches[1]] = matches[2]
+		}
+	}
+	return info
+}
+
+// SharedFolders returns the shared folder names of the VM mapped to their host paths
+func (i VMInfo) SharedFolders() map[string]string {
+	folders := make(map[string]string)
+	for k, name := range i {
+		if strings.HasPrefix(k, "SharedFolderName") {
+			folders[name] = i["SharedFolderPath"+strings.TrimPrefix(k, "SharedFolderName")]
+		}
+	}
+	return folders
+}
+
+// Disk returns the host path of the VM's boot2docker disk image, which docker-machine attaches to
+// the first port of the SATA controller
+func (i VMInfo) Disk() string {
+	return i["SATA-0-0"]
+}
+
+// parseCapacity parses the capacity in MB from the output of `VBoxManage showmediuminfo`
+func parseCapacity(output []byte) (int, bool) {
+	matches := regexp.MustCompile(`(?m)^Capacity:\s+(\d+) MBytes`).FindSubmatch(output)
+	if matches == nil {
+		return 0, false
+	}
+	size, err := strconv.Atoi(string(matches[1]))
+	return size, err == nil
+}
+
+// parseVMList parses the output from `VBoxManage list vms` and returns the VM names
+func parseVMList(output []byte) []string {
+	names := []string{}
+	re := regexp.MustCompile(`^"(.*)" \{[^}]*\}$`)
+	for _, line := range strings.Split(string(output), "\n") {
+		matches := re.FindStringSubmatch(strings.TrimSpace(line))
+		if len(matches) == 2 {
+			names = append(names, matches[1])
+		}
+	}
+	return names
+}
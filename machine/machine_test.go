@@ -1,8 +1,15 @@
 package machine
 
 import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/justincarter/docker-workbench/run"
 )
 
 func TestParseEnvOutput(t *testing.T) {
@@ -56,3 +63,357 @@ func TestValidIPv4_Invalid(t *testing.T) {
 		}
 	}
 }
+
+// useTestHost makes the tests independent of the CPUs and memory of this computer
+func useTestHost(t *testing.T) {
+	cpus, memory := HostCPUs, HostMemory
+	HostCPUs = func() int { return 8 }
+	HostMemory = func() int { return 16384 }
+	t.Cleanup(func() { HostCPUs, HostMemory = cpus, memory })
+}
+
+func TestCreate_Invocations(t *testing.T) {
+	useTestHost(t)
+	r := run.NewFakeRunner()
+	m := &Machine{Name: "workbench", Runner: r}
+	m.Create(context.Background())
+
+	expected := []string{"docker-machine create --driver virtualbox workbench"}
+	if !reflect.DeepEqual(expected, r.Calls()) {
+		t.Errorf("unexpected calls: %v", r.Calls())
+	}
+	env := []string{
+		"VIRTUALBOX_CPU_COUNT=2",
+		"VIRTUALBOX_DISK_SIZE=60000",
+		"VIRTUALBOX_MEMORY_SIZE=2048",
+		"VIRTUALBOX_NO_SHARE=true",
+	}
+	if !reflect.DeepEqual(env, r.Call(0).Env) {
+		t.Errorf("unexpected env: %v", r.Call(0).Env)
+	}
+}
+
+func TestCreate_Env(t *testing.T) {
+	useTestHost(t)
+	r := run.NewFakeRunner()
+	m := &Machine{Name: "workbench", Runner: r, Env: map[string]string{"VIRTUALBOX_MEMORY_SIZE": "4096"}}
+	m.Create(context.Background())
+
+	if env := m.CreateEnv(); env["VIRTUALBOX_MEMORY_SIZE"] != "4096" || env["VIRTUALBOX_CPU_COUNT"] != "2" {
+		t.Errorf("unexpected env: %v", env)
+	}
+	if env := r.Call(0).Env; len(env) != 4 || env[2] != "VIRTUALBOX_MEMORY_SIZE=4096" {
+		t.Errorf("unexpected env: %v", env)
+	}
+}
+
+func TestShareFolder_Invocations(t *testing.T) {
+	r := run.NewFakeRunner()
+	m := &Machine{Name: "workbench", Runner: r}
+	m.ShareFolder(context.Background(), "/d/workbench")
+
+	expected := []string{run.VBoxManagePath() + " sharedfolder add workbench --name workbench --hostpath /d/workbench"}
+	if !reflect.DeepEqual(expected, r.Calls()) {
+		t.Errorf("unexpected calls: %v", r.Calls())
+	}
+}
+
+func TestSSHStartStop_Invocations(t *testing.T) {
+	r := run.NewFakeRunner()
+	m := &Machine{Name: "workbench", Runner: r}
+	m.Start(context.Background())
+	m.SSH(context.Background(), "docker ps")
+	m.Stop(context.Background())
+
+	expected := []string{
+		"docker-machine start workbench",
+		"docker-machine ssh workbench docker ps",
+		"docker-machine stop workbench",
+	}
+	if !reflect.DeepEqual(expected, r.Calls()) {
+		t.Errorf("unexpected calls: %v", r.Calls())
+	}
+}
+
+func TestExists(t *testing.T) {
+	r := run.NewFakeRunner().On(run.VBoxManagePath()+" list vms", run.Response{
+		Stdout: "\"default\" {1f6c4f0e}\n\"workbench\" {9b2d1a7c}\n",
+	})
+	if exists, err := (&Machine{Name: "workbench", Runner: r}).Exists(context.Background()); !exists || err != nil {
+		t.Fail()
+	}
+	if exists, err := (&Machine{Name: "missing", Runner: r}).Exists(context.Background()); exists || err != nil {
+		t.Fail()
+	}
+}
+
+func TestIP(t *testing.T) {
+	r := run.NewFakeRunner().On("docker-machine ip workbench", run.Response{Stdout: "192.168.99.100\n"})
+	m := &Machine{Name: "workbench", Runner: r}
+	ip, err := m.IP(context.Background())
+	if err != nil || ip != "192.168.99.100" {
+		t.Fail()
+	}
+}
+
+func TestIP_NotFound(t *testing.T) {
+	r := run.NewFakeRunner().On("docker-machine ip", run.Response{Stderr: "Host does not exist: \"missing\"", ExitCode: 1})
+	m := &Machine{Name: "missing", Runner: r}
+	_, err := m.IP(context.Background())
+	if !errors.Is(err, ErrMachineNotFound) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestIP_Invalid(t *testing.T) {
+	r := run.NewFakeRunner().On("docker-machine ip", run.Response{Stdout: "\n"})
+	m := &Machine{Name: "workbench", Runner: r}
+	if _, err := m.IP(context.Background()); err != ErrNoIP {
+		t.Errorf("expected no IP error, got %v", err)
+	}
+}
+
+func TestSSH_CommandError(t *testing.T) {
+	r := run.NewFakeRunner().On("docker-machine ssh", run.Response{ExitCode: 125})
+	m := &Machine{Name: "workbench", Runner: r}
+	err := m.SSH(context.Background(), "docker run justincarter/docker-workbench-proxy")
+	var cmdErr *run.CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.ExitCode != 125 {
+		t.Errorf("expected command error, got %v", err)
+	}
+}
+
+func TestCreate_Failed(t *testing.T) {
+	r := run.NewFakeRunner().On("docker-machine create", run.Response{ExitCode: 1})
+	m := &Machine{Name: "workbench", Runner: r}
+	if err := m.Create(context.Background()); run.ExitCodeOf(err) != 1 {
+		t.Errorf("expected create to fail, got %v", err)
+	}
+}
+
+func TestParseSharedFolders(t *testing.T) {
+
+	input := `name="workbench"
+VMState="poweroff"
+SharedFolderNameMachineMapping1="workbench"
+SharedFolderPathMachineMapping1="/Users/justin/workbench"
+SharedFolderNameMachineMapping2="extra"
+SharedFolderPathMachineMapping2="C:\data"
+`
+	result := parseMachineReadable([]byte(input)).SharedFolders()
+
+	expected := map[string]string{
+		"workbench": "/Users/justin/workbench",
+		"extra":     "C:\\data",
+	}
+
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("unexpected shared folders: %v", result)
+	}
+
+}
+
+func TestParseVMList(t *testing.T) {
+	input := "\"default\" {1f6c4f0e-0d6b-4a4e-9b1c-4a1c6f0e2d3b}\n\"client A\" {9b2d1a7c-2f1e-4c1b-8d3a-7c2b1a0f9e8d}\n"
+	if result := parseVMList([]byte(input)); !reflect.DeepEqual([]string{"default", "client A"}, result) {
+		t.Errorf("unexpected VMs: %v", result)
+	}
+}
+
+func TestCheckResources(t *testing.T) {
+	cpus, memory := HostCPUs, HostMemory
+	HostCPUs = func() int { return 4 }
+	HostMemory = func() int { return 8192 }
+	defer func() { HostCPUs, HostMemory = cpus, memory }()
+
+	tests := []struct {
+		env   map[string]string
+		valid bool
+	}{
+		{map[string]string{}, true},
+		{map[string]string{"VIRTUALBOX_CPU_COUNT": "4", "VIRTUALBOX_MEMORY_SIZE": "8192", "VIRTUALBOX_DISK_SIZE": "5000"}, true},
+		{map[string]string{"VIRTUALBOX_CPU_COUNT": "5"}, false},
+		{map[string]string{"VIRTUALBOX_CPU_COUNT": "two"}, false},
+		{map[string]string{"VIRTUALBOX_MEMORY_SIZE": "16384"}, false},
+		{map[string]string{"VIRTUALBOX_MEMORY_SIZE": "512"}, false},
+		{map[string]string{"VIRTUALBOX_DISK_SIZE": "1000"}, false},
+	}
+	for _, test := range tests {
+		m := &Machine{Name: "workbench", Env: test.env}
+		if err := m.CheckResources(); (err == nil) != test.valid {
+			t.Errorf("%v: unexpected result %v", test.env, err)
+		}
+	}
+
+	// the defaults fit a computer with a single CPU
+	HostCPUs = func() int { return 1 }
+	m := &Machine{Name: "workbench"}
+	if err := m.CheckResources(); err != nil || m.CreateEnv()["VIRTUALBOX_CPU_COUNT"] != "1" {
+		t.Errorf("unexpected result: %v %v", m.CreateEnv(), err)
+	}
+}
+
+func TestValidName(t *testing.T) {
+	for name, valid := range map[string]bool{"workbench": true, "dev-2.local": true, "-dev": false, "my_workbench": false, "": false} {
+		if ValidName(name) != valid {
+			t.Errorf("ValidName(%q) should be %v", name, valid)
+		}
+	}
+}
+
+func TestParseCapacity(t *testing.T) {
+	size, ok := parseCapacity([]byte("UUID:           1234\nLocation:       /vms/disk.vmdk\nCapacity:       61440 MBytes\nSize on disk:   2048 MBytes\n"))
+	if !ok || size != 61440 {
+		t.Errorf("unexpected capacity: %d %v", size, ok)
+	}
+}
+
+func TestNewDriver(t *testing.T) {
+	for name, expected := range map[string]string{"": "virtualbox", "virtualbox": "virtualbox", "kvm2": "kvm2", "generic": "generic"} {
+		if d, err := NewDriver(name); err != nil || d.Name() != expected {
+			t.Errorf("NewDriver(%q) = %v, %v", name, d, err)
+		}
+	}
+	for _, name := range []string{"hyperv", "kvm"} {
+		if _, err := NewDriver(name); err == nil {
+			t.Errorf("expected the %s driver to fail", name)
+		}
+	}
+}
+
+func TestCreate_KVM(t *testing.T) {
+	os.Setenv("KVM_MEMORY", "4096")
+	defer os.Unsetenv("KVM_MEMORY")
+	r := run.NewFakeRunner()
+	m := &Machine{Name: "workbench", Driver: KVM{}, Runner: r, Env: map[string]string{"KVM_CPU_COUNT": "2"}}
+	m.Create(context.Background())
+
+	// the kvm2 plugin defines no create flags, so none may be passed
+	expected := []string{"docker-machine create --driver kvm2 workbench"}
+	if !reflect.DeepEqual(expected, r.Calls()) {
+		t.Errorf("unexpected calls: %v", r.Calls())
+	}
+}
+
+func TestKVMSharedFolders(t *testing.T) {
+	r := run.NewFakeRunner().On("virsh --connect qemu:///system dumpxml", run.Response{Stdout: `<domain type='kvm'>
+  <name>workbench</name>
+  <devices>
+    <disk type='file' device='disk'><source file='/var/lib/libvirt/images/workbench.rawdisk'/></disk>
+    <filesystem type='mount' accessmode='mapped'>
+      <source dir='/d/workbench'/>
+      <target dir='workbench'/>
+    </filesystem>
+  </devices>
+</domain>`})
+	m := &Machine{Name: "workbench", Driver: KVM{}, Runner: r}
+	folders, err := m.SharedFolders(context.Background())
+	if err != nil || !reflect.DeepEqual(map[string]string{"workbench": "/d/workbench"}, folders) {
+		t.Errorf("unexpected folders: %v %v", folders, err)
+	}
+
+	m.AddSharedFolder(context.Background(), "data", "/d/data")
+	if c := r.Call(1); len(c.Args) != 6 || c.Args[2] != "attach-device" || c.Args[5] != "--config" {
+		t.Errorf("unexpected call: %v", c)
+	}
+}
+
+func TestGenericSharedFolders(t *testing.T) {
+	r := run.NewFakeRunner().On("docker-machine ssh workbench grep", run.Response{Stdout: "/etc/docker-workbench/folders/workbench:/home/dev/workbench\n"})
+	m := &Machine{Name: "workbench", Driver: Generic{}, Runner: r}
+	folders, err := m.SharedFolders(context.Background())
+	if err != nil || !reflect.DeepEqual(map[string]string{"workbench": "/home/dev/workbench"}, folders) {
+		t.Errorf("unexpected folders: %v %v", folders, err)
+	}
+	if err := m.CheckResources(); err != nil {
+		t.Errorf("generic machines should not be checked: %v", err)
+	}
+}
+
+func TestNative(t *testing.T) {
+	root := t.TempDir()
+	original := NativeRoot
+	NativeRoot = root
+	defer func() { NativeRoot = original }()
+	os.Symlink("/d/workbench", filepath.Join(root, "workbench"))
+	os.Mkdir(filepath.Join(root, "etc"), 0755)
+
+	r := run.NewFakeRunner().
+		On("docker ps -aq --filter label=docker-workbench.name=workbench", run.Response{Stdout: "3f4e5d6c7b8a\n"}).
+		On("docker version", run.Response{Stderr: "Cannot connect to the Docker daemon", ExitCode: 1})
+	m := &Machine{Name: "workbench", Driver: Native{}, Runner: r}
+
+	if exists, err := m.Exists(context.Background()); !exists || err != nil {
+		t.Errorf("unexpected result: %v %v", exists, err)
+	}
+	if state, err := m.State(context.Background()); state != "Stopped" || err != nil {
+		t.Errorf("unexpected state: %s %v", state, err)
+	}
+	if ip, _ := m.IP(context.Background()); ip != "127.0.0.1" {
+		t.Errorf("unexpected IP: %s", ip)
+	}
+	folders, err := m.SharedFolders(context.Background())
+	if err != nil || !reflect.DeepEqual(map[string]string{"workbench": "/d/workbench"}, folders) {
+		t.Errorf("unexpected folders: %v %v", folders, err)
+	}
+
+	NativeRoot = filepath.Join(root, "missing")
+	if folders, err := m.SharedFolders(context.Background()); err != nil || len(folders) != 0 {
+		t.Errorf("unexpected folders: %v %v", folders, err)
+	}
+
+	m.SSH(context.Background(), "docker ps")
+	m.Remove(context.Background())
+	calls := r.Calls()
+	expected := []string{"sh -c docker ps", "docker ps -aq --filter label=docker-workbench.name=workbench", "docker rm -f 3f4e5d6c7b8a"}
+	if !reflect.DeepEqual(expected, calls[len(calls)-3:]) {
+		t.Errorf("unexpected calls: %v", calls)
+	}
+}
+
+func TestUpdateContext(t *testing.T) {
+	r := run.NewFakeRunner().
+		On("docker-machine env", run.Response{Stdout: "export DOCKER_TLS_VERIFY=\"1\"\nexport DOCKER_HOST=\"tcp://192.168.99.100:2376\"\nexport DOCKER_CERT_PATH=\"/m/workbench\"\n"}).
+		On("docker context inspect", run.Response{ExitCode: 1})
+	m := &Machine{Name: "workbench", Runner: r}
+
+	if err := m.UpdateContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expected := "docker context create workbench --description docker-workbench machine workbench " +
+		"--docker host=tcp://192.168.99.100:2376,ca=/m/workbench/ca.pem,cert=/m/workbench/cert.pem,key=/m/workbench/key.pem"
+	if calls := r.Calls(); calls[len(calls)-1] != expected {
+		t.Errorf("unexpected calls: %v", calls)
+	}
+
+	r.On("docker context inspect", run.Response{})
+	m.UpdateContext(context.Background())
+	if calls := r.Calls(); !strings.HasPrefix(calls[len(calls)-1], "docker context update workbench ") {
+		t.Errorf("existing context was not updated: %v", calls)
+	}
+}
+
+func TestContext_Native(t *testing.T) {
+	r := run.NewFakeRunner().On("docker context show", run.Response{Stdout: "default\n"})
+	m := &Machine{Name: "workbench", Driver: Native{}, Runner: r}
+
+	if err := m.UpdateContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RemoveContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !m.UsingContext(context.Background()) {
+		t.Error("native machines should use the default context")
+	}
+	if calls := r.Calls(); !reflect.DeepEqual([]string{"docker context show"}, calls) {
+		t.Errorf("unexpected calls: %v", calls)
+	}
+}
+
+func TestContextEndpoint(t *testing.T) {
+	env := map[string]string{"DOCKER_HOST": "tcp://10.0.0.5:2375"}
+	if endpoint := ContextEndpoint(env); endpoint != "host=tcp://10.0.0.5:2375" {
+		t.Errorf("unexpected endpoint without TLS: %s", endpoint)
+	}
+}
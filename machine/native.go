@@ -0,0 +1,201 @@
+package machine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/justincarter/docker-workbench/run"
+)
+
+// NativeRoot is the host directory native workbenches keep a symlink to each shared folder in.
+// The folder's mount (e.g. /workbench) links to its symlink here, which is how docker-workbench
+// tells its own links from anything else at that path.
+var NativeRoot = "/var/lib/docker-workbench/folders"
+
+// ErrDockerNotRunning is returned when a native workbench cannot reach the local Docker engine
+var ErrDockerNotRunning = errors.New("docker-workbench: the Docker engine is not running. Start Docker and try again")
+
+// Native is the Driver for workbenches that use the local Docker engine with no VM, such as on
+// Linux where Docker runs natively. The workbench is represented by its proxy container, shared
+// folders are symlinks in NativeRoot linked to from their mounts (e.g. /workbench) and apps are
+// browsed on 127.0.0.1.
+type Native struct{}
+
+// Host is implemented by drivers that run workbenches without docker-machine. Machine methods
+// that would run docker-machine call the Host instead.
+type Host interface {
+	Create(ctx context.Context, m *Machine) error
+	State(ctx context.Context, m *Machine) (string, error)
+	Start(ctx context.Context, m *Machine) error
+	Stop(ctx context.Context, m *Machine) error
+	Remove(ctx context.Context, m *Machine) error
+	EnvOutput(ctx context.Context, m *Machine) (map[string]string, error)
+	// Shell runs a command in the workbench, as docker-machine ssh does for a VM
+	Shell(ctx context.Context, m *Machine, command string) error
+	ShellOutput(ctx context.Context, m *Machine, command string) ([]byte, error)
+}
+
+// Label is the docker label naming the workbench a proxy container belongs to
+const Label = "docker-workbench.name"
+
+// Name is "native"
+func (Native) Name() string { return "native" }
+
+// Tools returns nothing, as only docker and docker-compose are needed
+func (Native) Tools() []Tool { return nil }
+
+// Vars is empty as the resources are those of this computer
+func (Native) Vars() Vars { return Vars{} }
+
+// Defaults returns nothing
+func (Native) Defaults() map[string]string { return map[string]string{} }
+
+// CreateArgs returns nothing
+func (Native) CreateArgs(env map[string]string) []string { return nil }
+
+// Exists returns true if there is a proxy container labelled with the workbench name
+func (Native) Exists(ctx context.Context, m *Machine) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+	out, err := m.runner().Output(ctx, "docker", "ps", "-aq", "--filter", "label="+Label+"="+m.Name)
+	if err != nil {
+		return false, err
+	}
+	return len(out) > 0, nil
+}
+
+// IP returns the loopback address
+func (Native) IP(ctx context.Context, m *Machine) (string, error) {
+	return "127.0.0.1", nil
+}
+
+// ShareFolder links the folder into NativeRoot under name
+func (Native) ShareFolder(ctx context.Context, m *Machine, name, path string) error {
+	return m.SSH(ctx, "sudo mkdir -p "+run.Quote(NativeRoot)+" && sudo ln -sfn "+run.Quote(path)+" "+run.Quote(filepath.Join(NativeRoot, name)))
+}
+
+// SharedFolders returns the symlinks in NativeRoot, mapping each name to its target
+func (Native) SharedFolders(ctx context.Context, m *Machine) (map[string]string, error) {
+	folders := make(map[string]string)
+	entries, err := os.ReadDir(NativeRoot)
+	if os.IsNotExist(err) {
+		return folders, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		if target, err := os.Readlink(filepath.Join(NativeRoot, e.Name())); err == nil {
+			folders[e.Name()] = target
+		}
+	}
+	return folders, nil
+}
+
+// RemoveSharedFolder removes the symlink from NativeRoot, along with the default mount /name if it
+// links to it
+func (Native) RemoveSharedFolder(ctx context.Context, m *Machine, name string) error {
+	link := filepath.Join(NativeRoot, name)
+	mount := run.Quote("/" + name)
+	return m.SSH(ctx, "{ [ \"$(readlink "+mount+")\" != "+run.Quote(link)+" ] || sudo rm -f "+mount+"; } && "+
+		"{ [ ! -L "+run.Quote(link)+" ] || sudo rm -f "+run.Quote(link)+"; }")
+}
+
+// MountCommand links the mount to the folder's symlink in NativeRoot, refusing to replace anything
+// at the mount that is not already such a link
+func (Native) MountCommand(name, folder, mount string) string {
+	link := run.Quote(filepath.Join(NativeRoot, name))
+	quoted := run.Quote(mount)
+	mkdir := ""
+	if dir := filepath.Dir(mount); dir != "/" {
+		mkdir = "sudo mkdir -p " + run.Quote(dir) + " && "
+	}
+	return "{ [ \"$(readlink " + quoted + ")\" = " + link + " ] || " +
+		"{ [ ! -e " + quoted + " ] && [ ! -L " + quoted + " ] && " + mkdir + "sudo ln -s " + link + " " + quoted + "; } || " +
+		"{ echo \"docker-workbench: " + mount + " already exists and was not made by docker-workbench\" >&2; exit 1; }; }"
+}
+
+// MountLinked returns a command that succeeds if the mount links to the folder's symlink in NativeRoot
+func (Native) MountLinked(name, mount string) string {
+	return "[ \"$(readlink " + run.Quote(mount) + ")\" = " + run.Quote(filepath.Join(NativeRoot, name)) + " ]"
+}
+
+// BootScript is empty
+func (Native) BootScript() string { return "" }
+
+// ShareStopped is false
+func (Native) ShareStopped() bool { return false }
+
+// Create checks that the local Docker engine is running
+func (d Native) Create(ctx context.Context, m *Machine) error {
+	_, err := d.EnvOutput(ctx, m)
+	return err
+}
+
+// State is Running if the local Docker engine is running, and Stopped if it is not
+func (Native) State(ctx context.Context, m *Machine) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+	_, err := m.runner().Output(ctx, "docker", "version", "--format", "{{.Server.Version}}")
+	switch {
+	case err == nil:
+		return "Running", nil
+	case run.ExitCodeOf(err) > 0:
+		return "Stopped", nil
+	}
+	return "", err
+}
+
+// Start returns ErrDockerNotRunning, as docker-workbench cannot start the Docker engine
+func (Native) Start(ctx context.Context, m *Machine) error {
+	return ErrDockerNotRunning
+}
+
+// Stop does nothing, leaving the Docker engine running
+func (Native) Stop(ctx context.Context, m *Machine) error {
+	return nil
+}
+
+// Remove removes the workbench proxy container
+func (Native) Remove(ctx context.Context, m *Machine) error {
+	ctx, cancel := context.WithTimeout(ctx, RemoveTimeout)
+	defer cancel()
+	out, err := m.runner().Output(ctx, "docker", "ps", "-aq", "--filter", "label="+Label+"="+m.Name)
+	ids := strings.Fields(string(out))
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	return m.runner().Run(ctx, "docker", append([]string{"rm", "-f"}, ids...)...)
+}
+
+// EnvOutput returns no variables, as docker already uses the local engine, or ErrDockerNotRunning
+func (d Native) EnvOutput(ctx context.Context, m *Machine) (map[string]string, error) {
+	state, err := d.State(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	if state != "Running" {
+		return nil, ErrDockerNotRunning
+	}
+	return map[string]string{}, nil
+}
+
+// Shell runs a command on this computer with sh
+func (Native) Shell(ctx context.Context, m *Machine, command string) error {
+	ctx, cancel := context.WithTimeout(ctx, SSHTimeout)
+	defer cancel()
+	return m.runner().Run(ctx, "sh", "-c", command)
+}
+
+// ShellOutput runs a command on this computer with sh and returns its output
+func (Native) ShellOutput(ctx context.Context, m *Machine, command string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, SSHTimeout)
+	defer cancel()
+	return m.runner().Output(ctx, "sh", "-c", command)
+}
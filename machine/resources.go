@@ -0,0 +1,82 @@
+package machine
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strconv"
+)
+
+// MinDiskSize is the smallest disk in MB a boot2docker machine is created with, leaving room for
+// the boot2docker persistent data and the proxy image
+const MinDiskSize = 5000
+
+// MinMemorySize is the least memory in MB boot2docker needs to run Docker
+const MinMemorySize = 1024
+
+// HostMemory returns the total physical memory of this computer in MB, or 0 if it is not known
+var HostMemory = hostMemory
+
+// HostCPUs returns the number of logical CPUs of this computer
+var HostCPUs = runtime.NumCPU
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9.-]*$`)
+
+// ValidName returns true if name can be used as a docker-machine machine name
+func ValidName(name string) bool {
+	return validName.MatchString(name)
+}
+
+// CheckResources returns an error if the CPUs, memory or disk size that CreateEnv would create
+// the machine with are not numbers, or more than this computer has, or too small for boot2docker.
+// Machines whose driver cannot set their resources are not checked.
+func (m *Machine) CheckResources() error {
+	vars := m.driver().Vars()
+	if vars.CPUs == "" {
+		return nil
+	}
+	env := m.CreateEnv()
+	cpus, err := envInt(env, vars.CPUs)
+	if err != nil {
+		return err
+	}
+	memory, err := envInt(env, vars.Memory)
+	if err != nil {
+		return err
+	}
+	disk, err := envInt(env, vars.DiskSize)
+	if err != nil {
+		return err
+	}
+
+	return ValidateResources(cpus, memory, disk)
+}
+
+// ValidateResources returns an error if the CPUs, memory (MB) or disk size (MB) are more than this
+// computer has or too small for boot2docker
+func ValidateResources(cpus, memory, disk int) error {
+	switch host := HostCPUs(); {
+	case cpus < 1:
+		return fmt.Errorf("docker-workbench: the machine needs at least 1 CPU")
+	case cpus > host:
+		return fmt.Errorf("docker-workbench: %d CPUs is more than the %d this computer has", cpus, host)
+	}
+	switch host := HostMemory(); {
+	case memory < MinMemorySize:
+		return fmt.Errorf("docker-workbench: %d MB of memory is less than the %d MB boot2docker needs", memory, MinMemorySize)
+	case host > 0 && memory > host:
+		return fmt.Errorf("docker-workbench: %d MB of memory is more than the %d MB this computer has", memory, host)
+	}
+	if disk < MinDiskSize {
+		return fmt.Errorf("docker-workbench: a %d MB disk is smaller than the %d MB minimum for boot2docker", disk, MinDiskSize)
+	}
+	return nil
+}
+
+func envInt(env map[string]string, key string) (int, error) {
+	n, err := strconv.Atoi(env[key])
+	if err != nil {
+		return 0, fmt.Errorf("docker-workbench: %s must be a number, not '%s'", key, env[key])
+	}
+	return n, nil
+}
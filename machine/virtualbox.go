@@ -0,0 +1,95 @@
+package machine
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/justincarter/docker-workbench/run"
+)
+
+// VirtualBox is the Driver for Oracle VirtualBox VMs running boot2docker
+type VirtualBox struct{}
+
+// Name is the docker-machine driver name
+func (VirtualBox) Name() string { return "virtualbox" }
+
+// Tools returns VBoxManage
+func (VirtualBox) Tools() []Tool {
+	return []Tool{{Path: run.VBoxManagePath(), Install: "VirtualBox"}}
+}
+
+// Vars are the VIRTUALBOX_* variables of the docker-machine virtualbox driver
+func (VirtualBox) Vars() Vars {
+	return Vars{
+		Prefix:   "VIRTUALBOX_",
+		CPUs:     "VIRTUALBOX_CPU_COUNT",
+		Memory:   "VIRTUALBOX_MEMORY_SIZE",
+		DiskSize: "VIRTUALBOX_DISK_SIZE",
+		ISO:      "VIRTUALBOX_BOOT2DOCKER_URL",
+	}
+}
+
+// Defaults returns the default resources, without docker-machine's own share of the home directory
+func (d VirtualBox) Defaults() map[string]string {
+	env := resourceDefaults(d.Vars())
+	env["VIRTUALBOX_NO_SHARE"] = "true"
+	return env
+}
+
+// CreateArgs returns nothing as the virtualbox driver reads its environment variables
+func (VirtualBox) CreateArgs(env map[string]string) []string { return nil }
+
+// Exists returns true if there is a VirtualBox VM for the machine
+func (VirtualBox) Exists(ctx context.Context, m *Machine) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+	out, err := m.runner().Output(ctx, run.VBoxManagePath(), "list", "vms")
+	if err != nil {
+		return false, err
+	}
+	re := regexp.MustCompile("(?mi)^\"" + regexp.QuoteMeta(m.Name) + "\"")
+	return re.Match(out), nil
+}
+
+// IP returns the IP address docker-machine reports for the VM
+func (VirtualBox) IP(ctx context.Context, m *Machine) (string, error) {
+	return dockerMachineIP(ctx, m)
+}
+
+// ShareFolder adds a VirtualBox shared folder to the VM
+func (VirtualBox) ShareFolder(ctx context.Context, m *Machine, name, path string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+	args := []string{"sharedfolder", "add", m.Name, "--name", name, "--hostpath", path}
+	return m.runner().Run(ctx, run.VBoxManagePath(), args...)
+}
+
+// SharedFolders returns the VirtualBox shared folders of the VM, mapping each name to its host path
+func (VirtualBox) SharedFolders(ctx context.Context, m *Machine) (map[string]string, error) {
+	info, err := m.VMInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return info.SharedFolders(), nil
+}
+
+// RemoveSharedFolder removes a VirtualBox shared folder from the VM
+func (VirtualBox) RemoveSharedFolder(ctx context.Context, m *Machine, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+	return m.runner().Run(ctx, run.VBoxManagePath(), "sharedfolder", "remove", m.Name, "--name", name)
+}
+
+// MountCommand mounts a VirtualBox shared folder as the boot2docker docker user
+func (VirtualBox) MountCommand(name, path, mount string) string {
+	return "sudo mkdir -p " + mount + " && sudo mount -t vboxsf -o uid=1000,gid=50 " + name + " " + mount
+}
+
+// BootScript is the boot2docker bootsync.sh
+func (VirtualBox) BootScript() string { return boot2dockerBootScript }
+
+// ShareStopped is true as VirtualBox only adds permanent shared folders to stopped VMs
+func (VirtualBox) ShareStopped() bool { return true }
+
+// boot2dockerBootScript is run by boot2docker at boot before Docker starts
+const boot2dockerBootScript = "/var/lib/boot2docker/bootsync.sh"
@@ -0,0 +1,138 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Recorder is a Runner that prints each command instead of running it, returning plausible stub
+// results so that a docker-workbench command can run to completion as if every command succeeded
+type Recorder struct {
+	Out io.Writer
+
+	// Reads runs the commands that list the existing machines, such as VBoxManage list vms, so that
+	// a dry run can find the workbench it is about. They are stubbed as listing nothing when nil.
+	Reads Runner
+
+	mu       sync.Mutex
+	machines []string
+}
+
+// proxyLabel matches the label naming the workbench of a native proxy container, whose docker run
+// creates the workbench
+var proxyLabel = regexp.MustCompile(`--label docker-workbench\.name=([A-Za-z0-9.-]+)`)
+
+// NewRecorder creates a Recorder that prints commands to out
+func NewRecorder(out io.Writer) *Recorder {
+	return &Recorder{Out: out}
+}
+
+// Run prints the command
+func (r *Recorder) Run(ctx context.Context, command string, args ...string) error {
+	_, _, err := r.OutputStderr(ctx, command, args...)
+	return err
+}
+
+// Output prints the command and returns its stub output
+func (r *Recorder) Output(ctx context.Context, command string, args ...string) ([]byte, error) {
+	out, _, err := r.OutputStderr(ctx, command, args...)
+	return out, err
+}
+
+// OutputStderr prints the command and returns its stub output
+func (r *Recorder) OutputStderr(ctx context.Context, command string, args ...string) ([]byte, []byte, error) {
+	if ctx.Err() != nil {
+		return nil, nil, contextError(ctx, 0, command, args)
+	}
+	out, err := r.record(ctx, command, args)
+	return []byte(out), nil, err
+}
+
+// ExitCode prints the command and returns a zero exit code
+func (r *Recorder) ExitCode(ctx context.Context, command string, args ...string) (int, error) {
+	if _, _, err := r.OutputStderr(ctx, command, args...); err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
+
+// record prints the command line with its environment and directory and returns the stub output for it
+func (r *Recorder) record(ctx context.Context, command string, args []string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	line := envCommandLine(EnvFrom(ctx), command, args)
+	if dir := DirFrom(ctx); dir != "" {
+		line = "cd " + Quote(dir) + " && " + line
+	}
+	fmt.Fprintf(r.Out, "[dry-run] %s\n", line)
+
+	return r.stub(ctx, filepath.Base(command), command, args)
+}
+
+// stub returns output that looks like a healthy docker-machine for the commands docker-workbench
+// parses. Machines created earlier in the dry run are listed after those found with Reads.
+func (r *Recorder) stub(ctx context.Context, base, command string, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", nil
+	}
+	name := args[len(args)-1]
+	if m := proxyLabel.FindStringSubmatch(strings.Join(args, " ")); m != nil {
+		r.machines = append(r.machines, m[1])
+	}
+	switch {
+	case base == "docker-machine" && args[0] == "create":
+		r.machines = append(r.machines, name)
+	case base == "docker-machine" && args[0] == "ip":
+		return "192.168.99.100\n", nil
+	case base == "docker-machine" && args[0] == "inspect":
+		return "virtualbox\n", nil
+	case base == "docker-machine" && args[0] == "env" && len(args) > 1:
+		return fmt.Sprintf("export DOCKER_TLS_VERIFY=\"1\"\nexport DOCKER_HOST=\"tcp://192.168.99.100:2376\"\nexport DOCKER_MACHINE_NAME=\"%s\"\n", args[1]), nil
+	case base == "docker-machine" && args[0] == "ls":
+		return r.list(ctx, command, args, "%s\n")
+	case strings.HasPrefix(base, "VBoxManage") && args[0] == "list":
+		return r.list(ctx, command, args, "\"%s\" {00000000-0000-0000-0000-000000000000}\n")
+	case base == "virsh" && len(args) > 2 && args[2] == "list":
+		return r.list(ctx, command, args, "%s\n")
+	case base == "virsh" && len(args) > 2 && args[2] == "dumpxml":
+		return "<domain><name>" + name + "</name><devices></devices></domain>\n", nil
+	case base == "docker" && args[0] == "ps" && strings.HasPrefix(name, "label="):
+		for _, m := range r.machines {
+			if strings.HasSuffix(name, "="+m) {
+				return "000000000000\n", nil
+			}
+		}
+		return r.list(ctx, command, args, "")
+	case strings.HasPrefix(base, "VBoxManage") && args[0] == "showvminfo":
+		return "cpus=2\nmemory=2048\n\"SATA-0-0\"=\"disk.vmdk\"\n", nil
+	case strings.HasPrefix(base, "VBoxManage") && args[0] == "showmediuminfo":
+		return "Capacity:       60000 MBytes\n", nil
+	}
+	return "", nil
+}
+
+// list runs a command that lists machines with Reads, adding each machine created in the dry run
+// in the given format unless it is empty
+func (r *Recorder) list(ctx context.Context, command string, args []string, format string) (string, error) {
+	out := ""
+	if r.Reads != nil {
+		b, err := r.Reads.Output(ctx, command, args...)
+		if err != nil {
+			return "", err
+		}
+		out = string(b)
+	}
+	if format == "" {
+		return out, nil
+	}
+	for _, m := range r.machines {
+		out += fmt.Sprintf(format, m)
+	}
+	return out, nil
+}
@@ -0,0 +1,124 @@
+package run
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// Call records a single command invocation made through a FakeRunner
+type Call struct {
+	Command string
+	Args    []string
+	Env     []string
+	Dir     string
+}
+
+// String returns the call as a space separated command line
+func (c Call) String() string {
+	return strings.TrimSpace(c.Command + " " + strings.Join(c.Args, " "))
+}
+
+// Response is a scripted result returned by a FakeRunner
+type Response struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Err      error
+}
+
+// FakeRunner is an in-memory Runner that records every call and returns scripted responses
+// instead of running anything. Responses are matched on the longest command line prefix.
+type FakeRunner struct {
+	mu        sync.Mutex
+	calls     []Call
+	responses map[string]Response
+}
+
+// NewFakeRunner creates a FakeRunner with no scripted responses
+func NewFakeRunner() *FakeRunner {
+	return &FakeRunner{responses: make(map[string]Response)}
+}
+
+// On scripts the response for any call whose command line starts with prefix
+func (f *FakeRunner) On(prefix string, r Response) *FakeRunner {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[prefix] = r
+	return f
+}
+
+// Calls returns the command lines of every call made so far, in order
+func (f *FakeRunner) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	lines := make([]string, len(f.calls))
+	for i, c := range f.calls {
+		lines[i] = c.String()
+	}
+	return lines
+}
+
+// Call returns the i'th call made so far, including any environment and directory added with
+// WithEnv and WithDir
+func (f *FakeRunner) Call(i int) Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[i]
+}
+
+// Run records the call and returns the scripted error
+func (f *FakeRunner) Run(ctx context.Context, command string, args ...string) error {
+	_, _, err := f.OutputStderr(ctx, command, args...)
+	return err
+}
+
+// Output records the call and returns the scripted output
+func (f *FakeRunner) Output(ctx context.Context, command string, args ...string) ([]byte, error) {
+	out, _, err := f.OutputStderr(ctx, command, args...)
+	return out, err
+}
+
+// OutputStderr records the call and returns the scripted output and error output
+func (f *FakeRunner) OutputStderr(ctx context.Context, command string, args ...string) ([]byte, []byte, error) {
+	r := f.record(ctx, command, args)
+	if ctx.Err() != nil {
+		return nil, nil, contextError(ctx, 0, command, args)
+	}
+	return []byte(r.Stdout), []byte(r.Stderr), responseError(command, args, r)
+}
+
+// ExitCode records the call and returns the scripted exit code
+func (f *FakeRunner) ExitCode(ctx context.Context, command string, args ...string) (int, error) {
+	r := f.record(ctx, command, args)
+	if ctx.Err() != nil {
+		return -1, contextError(ctx, 0, command, args)
+	}
+	return r.ExitCode, r.Err
+}
+
+func (f *FakeRunner) record(ctx context.Context, command string, args []string) Response {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := Call{Command: command, Args: append([]string(nil), args...), Env: EnvFrom(ctx), Dir: DirFrom(ctx)}
+	f.calls = append(f.calls, c)
+
+	line := c.String()
+	match := ""
+	for prefix := range f.responses {
+		if strings.HasPrefix(line, prefix) && len(prefix) >= len(match) {
+			match = prefix
+		}
+	}
+	return f.responses[match]
+}
+
+func responseError(command string, args []string, r Response) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	if r.ExitCode != 0 {
+		return &CommandError{Command: command, Args: args, ExitCode: r.ExitCode, Stderr: []byte(r.Stderr)}
+	}
+	return nil
+}
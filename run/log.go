@@ -0,0 +1,113 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Logger is a Runner that logs every command run through the Runner it wraps, with its
+// arguments, exit code, duration and any captured standard error
+type Logger struct {
+	Runner Runner
+	Out    io.Writer
+	// JSON writes one JSON object per line instead of human readable text
+	JSON bool
+
+	mu sync.Mutex
+}
+
+// LogEntry is a single logged command invocation
+type LogEntry struct {
+	Time       time.Time `json:"time"`
+	Command    string    `json:"command"`
+	Args       []string  `json:"args"`
+	Env        []string  `json:"env,omitempty"`
+	ExitCode   int       `json:"exit_code"`
+	DurationMS int64     `json:"duration_ms"`
+	Stderr     string    `json:"stderr,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// NewLogger creates a Logger that wraps runner and writes to out
+func NewLogger(runner Runner, out io.Writer, asJSON bool) *Logger {
+	return &Logger{Runner: runner, Out: out, JSON: asJSON}
+}
+
+// Run runs the command and logs it. Standard error is streamed to the console so is not logged.
+func (l *Logger) Run(ctx context.Context, command string, args ...string) error {
+	start := time.Now()
+	err := l.Runner.Run(ctx, command, args...)
+	l.log(ctx, start, command, args, nil, err)
+	return err
+}
+
+// Output runs the command and logs it along with its standard error
+func (l *Logger) Output(ctx context.Context, command string, args ...string) ([]byte, error) {
+	out, _, err := l.OutputStderr(ctx, command, args...)
+	return out, err
+}
+
+// OutputStderr runs the command and logs it along with its standard error
+func (l *Logger) OutputStderr(ctx context.Context, command string, args ...string) ([]byte, []byte, error) {
+	start := time.Now()
+	out, stderr, err := l.Runner.OutputStderr(ctx, command, args...)
+	l.log(ctx, start, command, args, stderr, err)
+	return out, stderr, err
+}
+
+// ExitCode runs the command and logs it
+func (l *Logger) ExitCode(ctx context.Context, command string, args ...string) (int, error) {
+	start := time.Now()
+	code, err := l.Runner.ExitCode(ctx, command, args...)
+	entry := l.entry(ctx, start, command, args, nil, err)
+	entry.ExitCode = code
+	l.write(entry)
+	return code, err
+}
+
+func (l *Logger) log(ctx context.Context, start time.Time, command string, args []string, stderr []byte, err error) {
+	l.write(l.entry(ctx, start, command, args, stderr, err))
+}
+
+func (l *Logger) entry(ctx context.Context, start time.Time, command string, args []string, stderr []byte, err error) LogEntry {
+	entry := LogEntry{
+		Time:       start,
+		Command:    command,
+		Args:       args,
+		Env:        EnvFrom(ctx),
+		ExitCode:   ExitCodeOf(err),
+		DurationMS: time.Since(start).Milliseconds(),
+		Stderr:     strings.TrimSpace(string(stderr)),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+func (l *Logger) write(e LogEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.JSON {
+		b, _ := json.Marshal(e)
+		fmt.Fprintf(l.Out, "%s\n", b)
+		return
+	}
+
+	line := envCommandLine(e.Env, e.Command, e.Args)
+	fmt.Fprintf(l.Out, "%s %s (exit %d, %dms)\n", e.Time.Format("15:04:05.000"), line, e.ExitCode, e.DurationMS)
+	if e.Error != "" && e.ExitCode == -1 {
+		fmt.Fprintf(l.Out, "    error: %s\n", e.Error)
+	}
+	if e.Stderr != "" {
+		for _, line := range strings.Split(e.Stderr, "\n") {
+			fmt.Fprintf(l.Out, "    stderr: %s\n", line)
+		}
+	}
+}
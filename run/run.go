@@ -1,22 +1,313 @@
 package run
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"os/exec"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
 )
 
+// KillDelay is how long a command is given to exit after being interrupted before it is killed
+var KillDelay = 5 * time.Second
+
+// Runner executes external commands on behalf of the machine and workbench packages
+type Runner interface {
+	// Run runs a command, streaming its output to the console
+	Run(ctx context.Context, command string, args ...string) error
+	// Output runs a command and returns its standard output
+	Output(ctx context.Context, command string, args ...string) ([]byte, error)
+	// OutputStderr runs a command and returns its standard output and standard error separately
+	OutputStderr(ctx context.Context, command string, args ...string) (stdout, stderr []byte, err error)
+	// ExitCode runs a command and returns its exit code, with an error only if the command could not be run
+	ExitCode(ctx context.Context, command string, args ...string) (int, error)
+}
+
+// Default is the Runner used by the package level helpers and by any machine without its own Runner
+var Default Runner = ExecRunner{}
+
+// TimeoutError is returned when a command is still running when its context deadline expires
+type TimeoutError struct {
+	Command string
+	Args    []string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("'%s' timed out after %s", CommandLine(e.Command, e.Args...), e.Timeout)
+}
+
+// CommandError is returned when a command runs but exits with a non-zero exit code
+type CommandError struct {
+	Command  string
+	Args     []string
+	ExitCode int
+	Stderr   []byte
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("'%s' failed with exit code %d", CommandLine(e.Command, e.Args...), e.ExitCode)
+	if stderr := strings.TrimSpace(string(e.Stderr)); stderr != "" {
+		lines := strings.Split(stderr, "\n")
+		msg += ": " + strings.TrimSpace(lines[len(lines)-1])
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCodeOf returns the exit code for the error returned by a Runner, which is 0 for a nil
+// error and -1 for an error other than a non-zero exit code (e.g. command not found)
+func ExitCodeOf(err error) int {
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.ExitCode
+	}
+	if err != nil {
+		return -1
+	}
+	return 0
+}
+
+// CommandLine joins a command and its arguments into a line that can be pasted into a shell
+func CommandLine(command string, args ...string) string {
+	parts := []string{Quote(command)}
+	for _, a := range args {
+		parts = append(parts, Quote(a))
+	}
+	return strings.Join(parts, " ")
+}
+
+// envCommandLine is CommandLine prefixed with the KEY=value environment variables for the command
+func envCommandLine(env []string, command string, args []string) string {
+	parts := []string{}
+	for _, e := range env {
+		parts = append(parts, Quote(e))
+	}
+	return strings.Join(append(parts, CommandLine(command, args...)), " ")
+}
+
+// Quote single quotes an argument for a POSIX shell if it contains anything other than safe characters
+func Quote(arg string) string {
+	if arg != "" && safeArg.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
+
+var safeArg = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+type envKey struct{}
+
+// WithEnv returns a context that adds the given KEY=value variables to the environment of any
+// command run with it, leaving the environment of docker-workbench itself untouched
+func WithEnv(ctx context.Context, env ...string) context.Context {
+	return context.WithValue(ctx, envKey{}, append(EnvFrom(ctx), env...))
+}
+
+// EnvFrom returns the extra environment variables added to ctx by WithEnv
+func EnvFrom(ctx context.Context) []string {
+	env, _ := ctx.Value(envKey{}).([]string)
+	return append([]string(nil), env...)
+}
+
+type dirKey struct{}
+
+// WithDir returns a context that runs any command run with it in dir rather than the current
+// working directory
+func WithDir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, dirKey{}, dir)
+}
+
+// DirFrom returns the working directory set on ctx by WithDir, or "" for the current directory
+func DirFrom(ctx context.Context) string {
+	dir, _ := ctx.Value(dirKey{}).(string)
+	return dir
+}
+
+type interactiveKey struct{}
+
+// Interactive returns a context that connects commands run with it to docker-workbench's standard
+// input and passes on the signals that would stop docker-workbench, so that the command decides for
+// itself how to exit. Only Run is affected.
+func Interactive(ctx context.Context) context.Context {
+	return context.WithValue(ctx, interactiveKey{}, true)
+}
+
+func isInteractive(ctx context.Context) bool {
+	interactive, _ := ctx.Value(interactiveKey{}).(bool)
+	return interactive
+}
+
+// ExecRunner is a Runner that executes commands using os/exec
+type ExecRunner struct{}
+
 // Run is helper for running a command with a variable number of string arguments
-func Run(command string, args ...string) error {
-	cmd := exec.Command(command, args...)
+func (ExecRunner) Run(ctx context.Context, command string, args ...string) error {
+	cmd := newCmd(ctx, command, args)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	if isInteractive(ctx) {
+		cmd.Stdin = os.Stdin
+	}
+	return commandError(wait(ctx, cmd), command, args, nil)
 }
 
 // Output is helper for running a command with a variable number of string arguments and returning its output
-func Output(command string, args ...string) ([]byte, error) {
+func (r ExecRunner) Output(ctx context.Context, command string, args ...string) ([]byte, error) {
+	out, _, err := r.OutputStderr(ctx, command, args...)
+	return out, err
+}
+
+// OutputStderr is helper for running a command and returning its output and error output
+func (ExecRunner) OutputStderr(ctx context.Context, command string, args ...string) ([]byte, []byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := newCmd(ctx, command, args)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := wait(ctx, cmd)
+	return stdout.Bytes(), stderr.Bytes(), commandError(err, command, args, stderr.Bytes())
+}
+
+// ExitCode is helper for running a command and returning its exit code
+func (r ExecRunner) ExitCode(ctx context.Context, command string, args ...string) (int, error) {
+	_, _, err := r.OutputStderr(ctx, command, args...)
+	var cmdErr *CommandError
+	if err != nil && !errors.As(err, &cmdErr) {
+		return -1, err
+	}
+	return ExitCodeOf(err), nil
+}
+
+// commandError wraps a non-zero exit from exec in a CommandError
+func commandError(err error, command string, args []string, stderr []byte) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &CommandError{Command: command, Args: args, ExitCode: exitErr.ExitCode(), Stderr: stderr, Err: err}
+	}
+	return err
+}
+
+// newCmd builds the exec.Cmd for a command, adding any environment variables and working directory
+// carried by ctx
+func newCmd(ctx context.Context, command string, args []string) *exec.Cmd {
 	cmd := exec.Command(command, args...)
-	return cmd.Output()
+	if env := EnvFrom(ctx); len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Dir = DirFrom(ctx)
+	return cmd
+}
+
+// wait starts cmd and waits for it to exit, interrupting and then killing it if ctx is done first
+func wait(ctx context.Context, cmd *exec.Cmd) error {
+	var timeout time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline).Round(time.Second)
+	}
+	if ctx.Err() != nil {
+		return contextError(ctx, timeout, cmd.Args[0], cmd.Args[1:])
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if isInteractive(ctx) {
+		defer forwardSignals(cmd.Process)()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		stop(cmd.Process, done)
+		return contextError(ctx, timeout, cmd.Args[0], cmd.Args[1:])
+	}
+}
+
+// forwardSignals passes the signals that would stop docker-workbench on to p until the returned
+// function is called. Interrupts are not passed on, as pressing Ctrl-C already sends them to every
+// process in the terminal and a second one would make commands like docker-compose up force quit.
+func forwardSignals(p *os.Process) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case s := <-signals:
+				if s != os.Interrupt {
+					p.Signal(s)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// stop sends an interrupt to the process so it can clean up, killing it if it has not exited after KillDelay
+func stop(p *os.Process, done <-chan error) {
+	if err := p.Signal(os.Interrupt); err != nil {
+		// interrupts are not supported on Windows
+		p.Kill()
+	}
+	select {
+	case <-done:
+		return
+	case <-time.After(KillDelay):
+	}
+	p.Kill()
+	select {
+	case <-done:
+	case <-time.After(KillDelay):
+	}
+}
+
+// contextError describes why a command was stopped by its context
+func contextError(ctx context.Context, timeout time.Duration, command string, args []string) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &TimeoutError{Command: command, Args: args, Timeout: timeout}
+	}
+	return fmt.Errorf("'%s' was interrupted: %w", CommandLine(command, args...), ctx.Err())
+}
+
+// Run is helper for running a command with a variable number of string arguments
+func Run(command string, args ...string) error {
+	return Default.Run(context.Background(), command, args...)
+}
+
+// Output is helper for running a command with a variable number of string arguments and returning its output
+func Output(command string, args ...string) ([]byte, error) {
+	return Default.Output(context.Background(), command, args...)
+}
+
+// RunContext is helper for running a command that is stopped when ctx is done
+func RunContext(ctx context.Context, command string, args ...string) error {
+	return Default.Run(ctx, command, args...)
+}
+
+// OutputContext is helper for running a command that is stopped when ctx is done and returning its output
+func OutputContext(ctx context.Context, command string, args ...string) ([]byte, error) {
+	return Default.Output(ctx, command, args...)
 }
 
 // VBoxManagePath returns the path to the VBoxManage executable
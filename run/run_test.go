@@ -1,9 +1,17 @@
 package run
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestRun(t *testing.T) {
@@ -42,3 +50,195 @@ func testVBoxManagePath(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestExitCode(t *testing.T) {
+	code, err := ExecRunner{}.ExitCode(context.Background(), "sh", "-c", "exit 3")
+	if code != 3 || err != nil {
+		t.Fail()
+	}
+}
+
+func TestOutputStderr(t *testing.T) {
+	out, errout, err := ExecRunner{}.OutputStderr(context.Background(), "sh", "-c", "echo out; echo err >&2")
+	if string(out) != "out\n" || string(errout) != "err\n" || err != nil {
+		t.Fail()
+	}
+}
+
+func TestFakeRunner(t *testing.T) {
+	f := NewFakeRunner().
+		On("docker-machine", Response{Stdout: "generic"}).
+		On("docker-machine ip", Response{Stdout: "192.168.99.100"}).
+		On("docker-machine status", Response{ExitCode: 1})
+
+	if out, _ := f.Output(context.Background(), "docker-machine", "ip", "workbench"); string(out) != "192.168.99.100" {
+		t.Fail()
+	}
+	if out, _ := f.Output(context.Background(), "docker-machine", "env", "workbench"); string(out) != "generic" {
+		t.Fail()
+	}
+	if err := f.Run(context.Background(), "docker-machine", "status", "workbench"); err == nil {
+		t.Fail()
+	}
+	if code, _ := f.ExitCode(context.Background(), "docker-machine", "status", "workbench"); code != 1 {
+		t.Fail()
+	}
+	if len(f.Calls()) != 4 || f.Calls()[0] != "docker-machine ip workbench" {
+		t.Fail()
+	}
+}
+
+func TestRunContext_Timeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := RunContext(ctx, "sleep", "10")
+	var timeout *TimeoutError
+	if !errors.As(err, &timeout) || timeout.Command != "sleep" {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("command was not stopped")
+	}
+}
+
+func TestRunContext_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	_, err := OutputContext(ctx, "sleep", "10")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancelled error, got %v", err)
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	line := CommandLine("docker-machine", "ssh", "workbench", "docker ps")
+	if line != `docker-machine ssh workbench 'docker ps'` {
+		t.Fail()
+	}
+}
+
+func TestQuote(t *testing.T) {
+	quoted := map[string]string{
+		"workbench":                "workbench",
+		"--hostpath=/d/workbench":  "--hostpath=/d/workbench",
+		"":                         "''",
+		"docker ps":                "'docker ps'",
+		"echo 'x' > /tmp/bootsync": `'echo '\''x'\'' > /tmp/bootsync'`,
+	}
+	for arg, expected := range quoted {
+		if Quote(arg) != expected {
+			t.Errorf("Quote(%q) = %s", arg, Quote(arg))
+		}
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	var out strings.Builder
+	r := NewRecorder(&out)
+	ctx := WithEnv(context.Background(), "VIRTUALBOX_CPU_COUNT=2")
+
+	if list, _ := r.Output(ctx, "VBoxManage", "list", "vms"); len(list) != 0 {
+		t.Fail()
+	}
+	r.Run(ctx, "docker-machine", "create", "--driver", "virtualbox", "workbench")
+	if list, _ := r.Output(context.Background(), "VBoxManage", "list", "vms"); !strings.HasPrefix(string(list), `"workbench"`) {
+		t.Fail()
+	}
+	if ip, _ := r.Output(context.Background(), "docker-machine", "ip", "workbench"); string(ip) != "192.168.99.100\n" {
+		t.Fail()
+	}
+
+	expected := "[dry-run] VIRTUALBOX_CPU_COUNT=2 VBoxManage list vms\n" +
+		"[dry-run] VIRTUALBOX_CPU_COUNT=2 docker-machine create --driver virtualbox workbench\n" +
+		"[dry-run] VBoxManage list vms\n" +
+		"[dry-run] docker-machine ip workbench\n"
+	if out.String() != expected {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestRecorder_Reads(t *testing.T) {
+	reads := NewFakeRunner().On("VBoxManage list vms", Response{Stdout: "\"existing\" {9b2d1a7c}\n"})
+	r := NewRecorder(io.Discard)
+	r.Reads = reads
+
+	r.Run(context.Background(), "docker-machine", "create", "--driver", "virtualbox", "workbench")
+	list, err := r.Output(context.Background(), "VBoxManage", "list", "vms")
+	expected := "\"existing\" {9b2d1a7c}\n\"workbench\" {00000000-0000-0000-0000-000000000000}\n"
+	if err != nil || string(list) != expected {
+		t.Errorf("unexpected list: %s %v", list, err)
+	}
+	if calls := reads.Calls(); !reflect.DeepEqual([]string{"VBoxManage list vms"}, calls) {
+		t.Errorf("unexpected reads: %v", calls)
+	}
+}
+
+func TestOutputStderr_CommandError(t *testing.T) {
+	_, _, err := ExecRunner{}.OutputStderr(context.Background(), "sh", "-c", "echo failed >&2; exit 2")
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.ExitCode != 2 || string(cmdErr.Stderr) != "failed\n" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if ExitCodeOf(err) != 2 || ExitCodeOf(nil) != 0 || ExitCodeOf(errors.New("not found")) != -1 {
+		t.Fail()
+	}
+}
+
+func TestLogger_Text(t *testing.T) {
+	var out strings.Builder
+	f := NewFakeRunner().On("docker-machine ip", Response{Stderr: "Host is not running", ExitCode: 1})
+	l := NewLogger(f, &out, false)
+	l.Output(context.Background(), "docker-machine", "ip", "workbench")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "docker-machine ip workbench (exit 1, ") || lines[1] != "    stderr: Host is not running" {
+		t.Errorf("unexpected log:\n%s", out.String())
+	}
+}
+
+func TestLogger_JSON(t *testing.T) {
+	var out strings.Builder
+	l := NewLogger(NewFakeRunner(), &out, true)
+	l.Run(WithEnv(context.Background(), "VIRTUALBOX_CPU_COUNT=2"), "docker-machine", "create", "workbench")
+
+	var entry LogEntry
+	if err := json.Unmarshal([]byte(out.String()), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Command != "docker-machine" || len(entry.Args) != 2 || entry.ExitCode != 0 || entry.Env[0] != "VIRTUALBOX_CPU_COUNT=2" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+}
+
+func TestWithDir(t *testing.T) {
+	dir, _ := filepath.EvalSymlinks(t.TempDir())
+	ctx := WithDir(context.Background(), dir)
+
+	out, err := ExecRunner{}.Output(ctx, "pwd")
+	if err != nil || strings.TrimSpace(string(out)) != dir {
+		t.Errorf("unexpected directory: %s %v", out, err)
+	}
+
+	var log strings.Builder
+	NewRecorder(&log).Run(WithDir(context.Background(), "/d/my workbench"), "docker-compose", "up")
+	if log.String() != "[dry-run] cd '/d/my workbench' && docker-compose up\n" {
+		t.Errorf("unexpected output: %s", log.String())
+	}
+}
+
+func TestInteractive_ForwardsSignals(t *testing.T) {
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		p, _ := os.FindProcess(os.Getpid())
+		p.Signal(syscall.SIGTERM)
+	}()
+
+	ctx := Interactive(context.Background())
+	err := ExecRunner{}.Run(ctx, "sh", "-c", "trap 'exit 7' TERM; while :; do sleep 0.1; done")
+	if code := ExitCodeOf(err); code != 7 {
+		t.Errorf("the command did not receive SIGTERM: %v", err)
+	}
+}
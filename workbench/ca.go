@@ -0,0 +1,243 @@
+package workbench
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CA is the local certificate authority that the proxy issues HTTPS certificates from. Its
+// certificate has to be installed on each device that browses the proxy over HTTPS.
+type CA struct {
+	Cert *x509.Certificate
+	// PEM is the CA certificate in PEM format, for installing on devices
+	PEM []byte
+	key *ecdsa.PrivateKey
+
+	mu    sync.Mutex
+	certs map[string]*tls.Certificate
+}
+
+// CAValidity is how long a newly created CA certificate is valid for
+var CAValidity = 10 * 365 * 24 * time.Hour
+
+// certValidity is how long the certificates the CA issues are valid for, which iOS limits to 825 days
+var certValidity = 365 * 24 * time.Hour
+
+// caPermittedIPRanges are the private address ranges the CA can issue certificates for
+var caPermittedIPRanges = []*net.IPNet{
+	{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)},
+	{IP: net.IP{172, 16, 0, 0}, Mask: net.CIDRMask(12, 32)},
+	{IP: net.IP{192, 168, 0, 0}, Mask: net.CIDRMask(16, 32)},
+	{IP: net.IP{127, 0, 0, 0}, Mask: net.CIDRMask(8, 32)},
+	{IP: net.IP{169, 254, 0, 0}, Mask: net.CIDRMask(16, 32)},
+}
+
+// CAPath returns the path of the CA certificate, which has its key alongside it
+func CAPath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ca", "ca.pem"), nil
+}
+
+// LoadCA loads the CA from the ConfigDir, creating it the first time. created is true if the CA
+// was created, in which case its certificate still needs to be installed on devices.
+func LoadCA() (ca *CA, created bool, err error) {
+	certPath, err := CAPath()
+	if err != nil {
+		return nil, false, err
+	}
+	keyPath := filepath.Join(filepath.Dir(certPath), "ca-key.pem")
+
+	certPEM, err := os.ReadFile(certPath)
+	if os.IsNotExist(err) {
+		ca, err = newCA()
+		if err != nil {
+			return nil, false, err
+		}
+		return ca, true, ca.save(certPath, keyPath)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, false, err
+	}
+	ca, err = parseCA(certPEM, keyPEM)
+	if err != nil {
+		return nil, false, fmt.Errorf("docker-workbench: invalid CA in %s: %s", filepath.Dir(certPath), err)
+	}
+	if !ca.Cert.PermittedDNSDomainsCritical {
+		return nil, false, fmt.Errorf("docker-workbench: the CA in %s is not limited to nip.io host names. Delete the folder to create a new CA, then reinstall its certificate on your devices", filepath.Dir(certPath))
+	}
+	return ca, false, nil
+}
+
+// newCA creates a CA with a new key. Its name constraints limit it to nip.io host names and
+// private addresses, so that devices trusting it cannot be fooled about any other site even if
+// its key leaks.
+func newCA() (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	template := &x509.Certificate{
+		SerialNumber:                serialNumber(),
+		Subject:                     pkix.Name{Organization: []string{"docker-workbench"}, CommonName: "docker-workbench local CA " + host},
+		NotBefore:                   time.Now().Add(-time.Hour),
+		NotAfter:                    time.Now().Add(CAValidity),
+		KeyUsage:                    x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid:       true,
+		IsCA:                        true,
+		MaxPathLenZero:              true,
+		PermittedDNSDomainsCritical: true,
+		PermittedDNSDomains:         []string{"nip.io"},
+		PermittedIPRanges:           caPermittedIPRanges,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return parseCA(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	)
+}
+
+// parseCA parses a PEM encoded CA certificate and key
+func parseCA(certPEM, keyPEM []byte) (*CA, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	keyBlock, _ := pem.Decode(keyPEM)
+	if certBlock == nil || keyBlock == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{Cert: cert, PEM: certPEM, key: key, certs: make(map[string]*tls.Certificate)}, nil
+}
+
+// save writes the CA certificate and key, keeping the key private to the user
+func (ca *CA) save(certPath, keyPath string) error {
+	if err := os.MkdirAll(filepath.Dir(certPath), 0700); err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(ca.key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(certPath, ca.PEM, 0644)
+}
+
+// Certificate returns a certificate for a nip.io host and its subdomains, e.g.
+// myapp.192.168.0.10.nip.io and *.myapp.192.168.0.10.nip.io, or for a private IP address.
+// Certificates are issued the first time each host is requested.
+func (ca *CA) Certificate(host string) (*tls.Certificate, error) {
+	host = strings.ToLower(host)
+	if !caPermits(host) {
+		return nil, fmt.Errorf("docker-workbench: the local CA only issues certificates for nip.io host names and private addresses, not %s", host)
+	}
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	if cert, ok := ca.certs[host]; ok {
+		return cert, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serialNumber(),
+		Subject:      pkix.Name{Organization: []string{"docker-workbench"}, CommonName: host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(certValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host, "*." + host}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, err
+	}
+	cert := &tls.Certificate{Certificate: [][]byte{der, ca.Cert.Raw}, PrivateKey: key}
+	ca.certs[host] = cert
+	return cert, nil
+}
+
+// caPermits reports whether host is within the name constraints of the CA
+func caPermits(host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		for _, r := range caPermittedIPRanges {
+			if r.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	return strings.HasSuffix(host, ".nip.io")
+}
+
+// TLSConfig returns a tls.Config that presents a certificate for the host name each client asks
+// for, or for the address it connected to if it did not ask for one. Host names must be for an
+// app that hasApp accepts on the address the client connected to, e.g. myapp.192.168.0.10.nip.io,
+// so that clients cannot make the proxy issue certificates for any other name.
+func (ca *CA) TLSConfig(hasApp func(app string) bool) *tls.Config {
+	return &tls.Config{
+		// HTTP/1.1 only, so that WebSocket upgrades work as they do over plain HTTP
+		NextProtos: []string{"http/1.1"},
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if hello.Conn == nil {
+				return nil, fmt.Errorf("docker-workbench: no connection for TLS handshake")
+			}
+			ip, _, _ := net.SplitHostPort(hello.Conn.LocalAddr().String())
+			host := strings.ToLower(hello.ServerName)
+			if host == "" || host == ip {
+				return ca.Certificate(ip)
+			}
+			app := strings.TrimSuffix(host, "."+ip+".nip.io")
+			if app == host || strings.Contains(app, ".") || !hasApp(app) {
+				return nil, fmt.Errorf("docker-workbench: no certificate for %s on %s", host, ip)
+			}
+			return ca.Certificate(host)
+		},
+	}
+}
+
+// serialNumber returns a random certificate serial number
+func serialNumber() *big.Int {
+	n, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	return n
+}
@@ -0,0 +1,114 @@
+package workbench
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoadCA(t *testing.T) {
+	useTempConfigDir(t)
+
+	ca, created, err := LoadCA()
+	if err != nil || !created {
+		t.Fatalf("CA was not created: %v", err)
+	}
+	if !ca.Cert.IsCA {
+		t.Error("certificate is not a CA")
+	}
+	if !ca.Cert.PermittedDNSDomainsCritical || len(ca.Cert.PermittedDNSDomains) != 1 || ca.Cert.PermittedDNSDomains[0] != "nip.io" ||
+		len(ca.Cert.PermittedIPRanges) == 0 {
+		t.Errorf("CA is not constrained to nip.io and private addresses: %v %v", ca.Cert.PermittedDNSDomains, ca.Cert.PermittedIPRanges)
+	}
+	loaded, created, err := LoadCA()
+	if err != nil || created || !loaded.Cert.Equal(ca.Cert) {
+		t.Errorf("the saved CA was not loaded: %v %v", created, err)
+	}
+}
+
+func TestCACertificate(t *testing.T) {
+	useTempConfigDir(t)
+	ca, _, _ := LoadCA()
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+
+	for host, names := range map[string][]string{
+		"myapp.192.168.0.10.nip.io": {"myapp.192.168.0.10.nip.io", "www.myapp.192.168.0.10.nip.io"},
+		"192.168.0.10":              {"192.168.0.10"},
+	} {
+		cert, err := ca.Certificate(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+		for _, name := range names {
+			if _, err := leaf.Verify(x509.VerifyOptions{DNSName: name, Roots: roots}); err != nil {
+				t.Errorf("certificate for %s is not valid for %s: %v", host, name, err)
+			}
+		}
+		if again, _ := ca.Certificate(host); again != cert {
+			t.Errorf("certificate for %s was issued again", host)
+		}
+	}
+
+	for _, host := range []string{"bank.example", "nip.io.example", "8.8.8.8"} {
+		if _, err := ca.Certificate(host); err == nil {
+			t.Errorf("certificate was issued for %s", host)
+		}
+	}
+}
+
+func TestProxy_TLS(t *testing.T) {
+	useTempConfigDir(t)
+	useTestBackend(t, nil)
+	ca, _, _ := LoadCA()
+	w := newTestWorkbench(nil)
+	w.App = "myapp"
+
+	proxy := httptest.NewUnstartedServer(w.proxyHandler("127.0.0.1", ProxyOptions{}))
+	proxy.TLS = ca.TLSConfig(w.hasApp)
+	proxy.StartTLS()
+	defer proxy.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "myapp.127.0.0.1.nip.io"},
+	}}
+	req, _ := http.NewRequest("GET", proxy.URL, nil)
+	req.Host = "myapp.127.0.0.1.nip.io:8443"
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || string(body) != "myapp.127.0.0.1.nip.io:8443" {
+		t.Errorf("unexpected response: %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestProxy_TLSRejectsOtherHosts(t *testing.T) {
+	useTempConfigDir(t)
+	ca, _, _ := LoadCA()
+	w := newTestWorkbench(nil)
+	w.App = "myapp"
+	proxy := httptest.NewUnstartedServer(http.NotFoundHandler())
+	proxy.TLS = ca.TLSConfig(w.hasApp)
+	proxy.StartTLS()
+	defer proxy.Close()
+
+	for _, sni := range []string{"bank.example", "myapp.192.168.0.10.nip.io", "other.127.0.0.1.nip.io", "www.myapp.127.0.0.1.nip.io"} {
+		conn, err := tls.Dial("tcp", proxy.Listener.Addr().String(), &tls.Config{ServerName: sni, InsecureSkipVerify: true})
+		if err == nil {
+			conn.Close()
+			t.Errorf("handshake for %s succeeded", sni)
+		}
+	}
+	if len(ca.certs) != 0 {
+		t.Errorf("certificates were issued for rejected hosts: %d", len(ca.certs))
+	}
+}
@@ -0,0 +1,145 @@
+package workbench
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/justincarter/docker-workbench/machine"
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigFile is the name of the optional configuration file in a workbench directory
+const ConfigFile = ".workbench.yml"
+
+// Config is the per-workbench configuration loaded from ConfigFile. It is meant to be committed
+// alongside the apps so that everyone using the workbench gets the same machine. Settings left
+// out of the file use the docker-workbench defaults.
+type Config struct {
+	// Driver is the docker-machine driver for new machines: virtualbox, kvm2, generic or native
+	Driver string `yaml:"driver"`
+
+	// CPUs, Memory (MB) and DiskSize (MB) of the machine, and the boot2docker ISO URL to create it from
+	CPUs     int    `yaml:"cpus"`
+	Memory   int    `yaml:"memory"`
+	DiskSize int    `yaml:"disk_size"`
+	ISO      string `yaml:"iso"`
+
+	// ProxyImage is the image used for the docker_workbench_proxy container
+	ProxyImage string `yaml:"proxy_image"`
+
+	// SharedFolders are extra host folders to share into the machine alongside /workbench
+	SharedFolders []SharedFolder `yaml:"shared_folders"`
+
+	Hooks Hooks `yaml:"hooks"`
+}
+
+// SharedFolder is a host folder shared into the machine
+type SharedFolder struct {
+	Name string `yaml:"name"`
+	// Path is the host path, relative to the workbench directory if not absolute
+	Path string `yaml:"path"`
+	// Mount is where the folder is mounted in the machine, defaulting to /Name
+	Mount string `yaml:"mount"`
+}
+
+// Hooks are shell commands run on the host from the workbench directory, with WORKBENCH_NAME and
+// WORKBENCH_DIR set in their environment
+type Hooks struct {
+	PostCreate []string `yaml:"post_create"`
+	PostUp     []string `yaml:"post_up"`
+}
+
+// LoadConfig loads the ConfigFile from a workbench directory, returning the default configuration
+// if there isn't one
+func LoadConfig(dir string) (*Config, error) {
+	cfg := &Config{ProxyImage: ProxyImage}
+	b, err := os.ReadFile(filepath.Join(dir, ConfigFile))
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.UnmarshalStrict(b, cfg); err != nil {
+		return nil, fmt.Errorf("docker-workbench: invalid %s: %s", ConfigFile, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("docker-workbench: invalid %s: %s", ConfigFile, err)
+	}
+	return cfg, nil
+}
+
+var (
+	folderName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	mountPath  = regexp.MustCompile(`^/[A-Za-z0-9_./-]+$`)
+)
+
+func (c *Config) validate() error {
+	if _, err := machine.NewDriver(c.Driver); err != nil {
+		return fmt.Errorf("unknown driver '%s'", c.Driver)
+	}
+	if c.CPUs < 0 || c.Memory < 0 || c.DiskSize < 0 {
+		return fmt.Errorf("cpus, memory and disk_size must be positive")
+	}
+	names := map[string]bool{"workbench": true}
+	for _, f := range c.SharedFolders {
+		if f.Name == "" || f.Path == "" {
+			return fmt.Errorf("shared folders need a name and a path")
+		}
+		if !folderName.MatchString(f.Name) || (f.Mount != "" && !mountPath.MatchString(f.Mount)) {
+			return fmt.Errorf("shared folder '%s' has an invalid name or mount", f.Name)
+		}
+		if names[f.Name] {
+			return fmt.Errorf("shared folder '%s' is defined more than once", f.Name)
+		}
+		names[f.Name] = true
+	}
+	return nil
+}
+
+// MachineDefaults returns the docker-machine environment variables for the settings in the file,
+// named for the driver's variables. Settings the driver cannot use are left out.
+func (c *Config) MachineDefaults(vars machine.Vars) map[string]string {
+	env := make(map[string]string)
+	for key, value := range map[string]int{vars.CPUs: c.CPUs, vars.Memory: c.Memory, vars.DiskSize: c.DiskSize} {
+		if key != "" && value > 0 {
+			env[key] = strconv.Itoa(value)
+		}
+	}
+	if vars.ISO != "" && c.ISO != "" {
+		env[vars.ISO] = c.ISO
+	}
+	return env
+}
+
+// Folders returns every folder to share into the machine, starting with the workbench directory
+// itself, with absolute host paths
+func (c *Config) Folders(dir string) []SharedFolder {
+	folders := []SharedFolder{{Name: "workbench", Path: dir, Mount: "/workbench"}}
+	for _, f := range c.SharedFolders {
+		if !filepath.IsAbs(f.Path) {
+			f.Path = filepath.Join(dir, f.Path)
+		}
+		if f.Mount == "" {
+			f.Mount = "/" + f.Name
+		}
+		folders = append(folders, f)
+	}
+	return folders
+}
+
+// mountScript returns the script that mounts the shared folders in the machine, which is run on
+// boot from the driver's boot script
+func mountScript(d machine.Driver, folders []SharedFolder) string {
+	mounts := []string{}
+	for _, f := range folders {
+		if c := d.MountCommand(f.Name, f.Path, f.Mount); c != "" {
+			mounts = append(mounts, c)
+		}
+	}
+	return strings.Join(mounts, " && ")
+}
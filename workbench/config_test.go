@@ -0,0 +1,108 @@
+package workbench
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/justincarter/docker-workbench/machine"
+	"github.com/justincarter/docker-workbench/run"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ConfigFile), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLoadConfig_Missing(t *testing.T) {
+	cfg, err := LoadConfig(t.TempDir())
+	if err != nil || !reflect.DeepEqual(&Config{ProxyImage: ProxyImage}, cfg) {
+		t.Errorf("unexpected config: %+v %v", cfg, err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := writeConfig(t, `
+cpus: 4
+memory: 4096
+iso: https://example.com/boot2docker.iso
+proxy_image: example/proxy
+shared_folders:
+  - name: shared
+    path: ../shared
+hooks:
+  post_up:
+    - echo up
+`)
+	cfg, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"VIRTUALBOX_CPU_COUNT":       "4",
+		"VIRTUALBOX_MEMORY_SIZE":     "4096",
+		"VIRTUALBOX_BOOT2DOCKER_URL": "https://example.com/boot2docker.iso",
+	}
+	if !reflect.DeepEqual(expected, cfg.MachineDefaults(machine.VirtualBox{}.Vars())) {
+		t.Errorf("unexpected machine defaults: %v", cfg.MachineDefaults(machine.VirtualBox{}.Vars()))
+	}
+	if cfg.ProxyImage != "example/proxy" || !reflect.DeepEqual([]string{"echo up"}, cfg.Hooks.PostUp) {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	folders := cfg.Folders(dir)
+	expectedFolders := []SharedFolder{
+		{Name: "workbench", Path: dir, Mount: "/workbench"},
+		{Name: "shared", Path: filepath.Join(filepath.Dir(dir), "shared"), Mount: "/shared"},
+	}
+	if !reflect.DeepEqual(expectedFolders, folders) {
+		t.Errorf("unexpected folders: %+v", folders)
+	}
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	for _, content := range []string{
+		"memroy: 4096",
+		"cpus: -1",
+		"shared_folders:\n  - name: workbench\n    path: /tmp",
+		"shared_folders:\n  - name: \"it's\"\n    path: /tmp",
+		"shared_folders:\n  - name: data\n",
+	} {
+		if _, err := LoadConfig(writeConfig(t, content)); err == nil {
+			t.Errorf("expected %q to be invalid", content)
+		}
+	}
+}
+
+func TestMountScript(t *testing.T) {
+	folders := []SharedFolder{
+		{Name: "workbench", Path: "/d/workbench", Mount: "/workbench"},
+		{Name: "data", Path: "/d/data", Mount: "/mnt/data"},
+	}
+	expected := "sudo mkdir -p /workbench && sudo mount -t vboxsf -o uid=1000,gid=50 workbench /workbench && " +
+		"sudo mkdir -p /mnt/data && sudo mount -t vboxsf -o uid=1000,gid=50 data /mnt/data"
+	if script := mountScript(machine.VirtualBox{}, folders); script != expected {
+		t.Errorf("unexpected script: %s", script)
+	}
+}
+
+func TestRunHooks(t *testing.T) {
+	r := run.NewFakeRunner().On("sh -c cd /d/workbench && false", run.Response{ExitCode: 1})
+	w := newTestWorkbench(r)
+	err := w.RunHooks(context.Background(), []string{"make setup", "false", "never"})
+	if err == nil {
+		t.Fatal("expected the failing hook to be reported")
+	}
+	calls := r.Calls()
+	if len(calls) != 2 || calls[0] != "sh -c cd /d/workbench && make setup" {
+		t.Errorf("unexpected calls: %v", calls)
+	}
+	if env := r.Call(0).Env; !reflect.DeepEqual([]string{"WORKBENCH_NAME=workbench", "WORKBENCH_DIR=/d/workbench"}, env) {
+		t.Errorf("unexpected env: %v", env)
+	}
+}
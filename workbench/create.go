@@ -0,0 +1,520 @@
+package workbench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/justincarter/docker-workbench/machine"
+	"github.com/justincarter/docker-workbench/run"
+)
+
+// ConfigDir returns the directory docker-workbench keeps its own files in
+var ConfigDir = func() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "docker-workbench"), nil
+}
+
+// DryRun keeps records in memory instead of saving or removing them while commands are only being
+// printed, so that later commands in the same run still find the machine
+var DryRun bool
+
+// ProxyImage is the default image of the reverse proxy container that routes requests to apps by host name
+const ProxyImage = "justincarter/docker-workbench-proxy"
+
+// ProxyContainer is the name of the reverse proxy container
+const ProxyContainer = "docker_workbench_proxy"
+
+// Step is a single step in creating a workbench machine
+type Step struct {
+	Name string
+	// Needs is the machine state the step needs ("Running" or "Stopped"), or empty if it doesn't matter
+	Needs string
+	Do    func(ctx context.Context) error
+	// Undo reverses the step when a create is rolled back, and may be nil
+	Undo func(ctx context.Context) error
+	// Check describes how the machine has drifted from what the step configures, returning an
+	// empty string if it hasn't. Steps without a Check are not re-applied by Provision.
+	Check func(ctx context.Context) (string, error)
+	// Fix re-applies the step to a machine that has drifted, defaulting to Do when nil
+	Fix func(ctx context.Context) error
+	// Restart is set if a fix only takes effect once the machine has been restarted
+	Restart bool
+}
+
+// Record is what docker-workbench remembers about a machine it created, including how far
+// creating it got so that a failed create can be resumed
+type Record struct {
+	Name   string `json:"name"`
+	Folder string `json:"folder"`
+	// Driver is the docker-machine driver the machine was created with, where empty is VirtualBox
+	Driver string `json:"driver,omitempty"`
+	// Env is the docker-machine environment the machine was created with
+	Env       map[string]string `json:"env"`
+	Completed []string          `json:"completed"`
+}
+
+// CreateSteps returns the steps that create and provision a new workbench machine
+func (w *Workbench) CreateSteps() []Step {
+	m := &w.Machine
+	d := driverOf(m)
+	folders := w.Config.Folders(w.Dir)
+	proxy := "docker run -d --restart=always --name=" + ProxyContainer + " --label " + machine.Label + "=" + m.Name +
+		" -p 80:80 -v '/var/run/docker.sock:/tmp/docker.sock:ro' " + w.Config.ProxyImage
+	removeProxy := func(ctx context.Context) error {
+		return m.SSH(ctx, "docker rm -f "+ProxyContainer+" 2> /dev/null || true")
+	}
+	// shareFolders adds the folders that are missing and replaces those pointing elsewhere, so that
+	// it can be run again after some of the folders were shared
+	shareFolders := func(ctx context.Context) error {
+		current, err := m.SharedFolders(ctx)
+		if err != nil {
+			return err
+		}
+		for _, f := range folders {
+			if folderDrift(f, current) == "" {
+				continue
+			}
+			if _, ok := current[f.Name]; ok {
+				if err := m.RemoveSharedFolder(ctx, f.Name); err != nil {
+					return err
+				}
+			}
+			if err := m.AddSharedFolder(ctx, f.Name, f.Path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	steps := []Step{
+		{
+			Name: "create",
+			Do:   m.Create,
+			Undo: m.Remove,
+		},
+		{
+			Name:  "env",
+			Needs: "Running",
+			Do:    m.EvalEnv,
+		},
+	}
+	if mountScript(d, folders) != "" {
+		steps = append(steps, mountStep(m, d, folders))
+	}
+	steps = append(steps, Step{
+		Name:  "proxy",
+		Needs: "Running",
+		Do: func(ctx context.Context) error {
+			fmt.Println("Installing Docker Workbench Proxy...")
+			return m.SSH(ctx, proxy)
+		},
+		Undo: removeProxy,
+		Check: func(ctx context.Context) (string, error) {
+			out, err := m.SSHOutput(ctx, "docker inspect -f '{{.State.Running}} {{.Config.Image}}' "+ProxyContainer)
+			if err != nil && run.ExitCodeOf(err) <= 0 {
+				return "", err
+			}
+			fields := strings.Fields(string(out))
+			switch {
+			case err != nil:
+				return ProxyContainer + " container is missing", nil
+			case len(fields) != 2 || fields[0] != "true":
+				return ProxyContainer + " container is not running", nil
+			case fields[1] != w.Config.ProxyImage:
+				return fmt.Sprintf("%s container is running %s instead of %s", ProxyContainer, fields[1], w.Config.ProxyImage), nil
+			}
+			return "", nil
+		},
+		Fix: func(ctx context.Context) error {
+			if err := removeProxy(ctx); err != nil {
+				return err
+			}
+			return m.SSH(ctx, proxy)
+		},
+	})
+
+	shareState := "Running"
+	if d.ShareStopped() {
+		shareState = "Stopped"
+		steps = append(steps, Step{
+			Name: "stop",
+			Do:   m.Stop,
+		})
+	}
+	return append(steps, Step{
+		Name:  "sharedfolder",
+		Needs: shareState,
+		Do: func(ctx context.Context) error {
+			fmt.Println("Adding /workbench shared folder...")
+			return shareFolders(ctx)
+		},
+		Check: func(ctx context.Context) (string, error) {
+			current, err := m.SharedFolders(ctx)
+			if err != nil {
+				return "", err
+			}
+			for _, f := range folders {
+				if drift := folderDrift(f, current); drift != "" {
+					return drift, nil
+				}
+			}
+			return "", nil
+		},
+		Fix: shareFolders,
+	})
+}
+
+// mountStep returns the step that mounts the shared folders in the machine. When the driver has a
+// boot script the mounts are written to it, otherwise they are made directly, or linked for native
+// workbenches.
+func mountStep(m *machine.Machine, d machine.Driver, folders []SharedFolder) Step {
+	script := mountScript(d, folders)
+	boot := d.BootScript()
+	if boot == "" {
+		return Step{
+			Name:  "bootsync",
+			Needs: "Running",
+			Do: func(ctx context.Context) error {
+				fmt.Println("Mounting shared folders...")
+				return m.SSH(ctx, script)
+			},
+			Check: func(ctx context.Context) (string, error) {
+				if native, ok := d.(machine.Native); ok {
+					for _, f := range folders {
+						_, err := m.SSHOutput(ctx, native.MountLinked(f.Name, f.Mount))
+						if err != nil && run.ExitCodeOf(err) <= 0 {
+							return "", err
+						}
+						if err != nil {
+							return f.Mount + " is not linked to the " + f.Name + " shared folder", nil
+						}
+					}
+					return "", nil
+				}
+				out, err := m.SSHOutput(ctx, "cat /proc/mounts")
+				if err != nil {
+					return "", err
+				}
+				for _, f := range folders {
+					if !strings.Contains(string(out), " "+f.Mount+" ") {
+						return f.Mount + " is not mounted", nil
+					}
+				}
+				return "", nil
+			},
+		}
+	}
+
+	name := path.Base(boot)
+	return Step{
+		Name:    "bootsync",
+		Needs:   "Running",
+		Restart: true,
+		Do: func(ctx context.Context) error {
+			fmt.Printf("Configuring %s...\n", name)
+			for _, c := range []string{
+				"sudo echo '" + script + "' >  /tmp/" + name,
+				"sudo cp /tmp/" + name + " " + boot,
+				"sudo chmod +x " + boot,
+			} {
+				if err := m.SSH(ctx, c); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Check: func(ctx context.Context) (string, error) {
+			out, err := m.SSHOutput(ctx, "cat "+boot)
+			if err != nil && run.ExitCodeOf(err) <= 0 {
+				return "", err
+			}
+			switch {
+			case err != nil:
+				return name + " is missing", nil
+			case strings.TrimSpace(string(out)) != script:
+				return name + " has been changed", nil
+			}
+			return "", nil
+		},
+	}
+}
+
+// driverOf returns the driver of a machine, which is VirtualBox if it isn't set
+func driverOf(m *machine.Machine) machine.Driver {
+	if m.Driver == nil {
+		return machine.VirtualBox{}
+	}
+	return m.Driver
+}
+
+// folderDrift describes how a shared folder differs from the machine's current shared folders,
+// returning an empty string if it doesn't
+func folderDrift(f SharedFolder, current map[string]string) string {
+	path, ok := current[f.Name]
+	switch {
+	case !ok:
+		return fmt.Sprintf("%s shared folder is missing", f.Name)
+	case path != f.Path:
+		return fmt.Sprintf("%s shared folder points to %s", f.Name, path)
+	}
+	return ""
+}
+
+// checkOnlyNative returns an error if the machine is native and there is already another native
+// workbench, as they would share the /workbench link and the proxy
+func checkOnlyNative(m *machine.Machine) error {
+	if _, ok := driverOf(m).(machine.Native); !ok {
+		return nil
+	}
+	records, err := LoadRecords()
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if record.Driver == m.Driver.Name() && record.Name != m.Name {
+			return fmt.Errorf("docker-workbench: %s is already the native workbench '%s', and there can only be one per computer. Destroy it before creating another", record.Folder, record.Name)
+		}
+	}
+	return nil
+}
+
+// Create creates and provisions the workbench machine, doing nothing if the machine already
+// exists. If a previous create failed part way through, it resumes from the failed step. When a
+// step fails its progress is saved for the next attempt, unless rollback is true in which case
+// the completed steps are undone, removing the machine. The post_create hooks are run once the
+// machine has been created.
+func (w *Workbench) Create(ctx context.Context, rollback bool) error {
+	m := &w.Machine
+	exists, err := m.Exists(ctx)
+	if err != nil {
+		return err
+	}
+	record, err := LoadRecord(m.Name)
+	if err != nil {
+		return err
+	}
+	steps := w.CreateSteps()
+
+	switch {
+	case !exists:
+		if err := m.CheckResources(); err != nil {
+			return err
+		}
+		if err := checkOnlyNative(m); err != nil {
+			return err
+		}
+		record = &Record{Name: m.Name, Folder: w.Dir, Driver: driverOf(m).Name(), Env: m.CreateEnv()}
+	case record == nil || record.done(steps):
+		return nil
+	default:
+		fmt.Printf("Resuming creation of \"%s\"...\n", m.Name)
+	}
+
+	resuming := exists
+	for i, step := range steps {
+		if record.completed(step.Name) {
+			continue
+		}
+		if resuming {
+			if err := ensureState(ctx, m, step.Needs); err != nil {
+				return err
+			}
+			// clear up anything left behind when the step failed last time before retrying it
+			if step.Undo != nil {
+				if err := step.Undo(ctx); err != nil {
+					return err
+				}
+			}
+			resuming = false
+		}
+		if err := step.Do(ctx); err != nil {
+			if rollback {
+				return rollbackSteps(m.Name, steps[:i+1], err)
+			}
+			if serr := record.Save(); serr != nil {
+				return serr
+			}
+			return fmt.Errorf("%s\nCreating the workbench failed at step '%s'. Run docker-workbench create again to resume", err, step.Name)
+		}
+		record.Completed = append(record.Completed, step.Name)
+		if err := record.Save(); err != nil {
+			return err
+		}
+	}
+	return w.RunHooks(ctx, w.Config.Hooks.PostCreate)
+}
+
+// rollbackSteps undoes the given steps in reverse order after cause made creating the machine fail
+func rollbackSteps(name string, steps []Step, cause error) error {
+	// undo even if the user pressed Ctrl-C, as that is often why the step failed
+	ctx := context.Background()
+	fmt.Println("Rolling back...")
+	for i := len(steps) - 1; i >= 0; i-- {
+		if steps[i].Undo == nil {
+			continue
+		}
+		if err := steps[i].Undo(ctx); err != nil {
+			return fmt.Errorf("%s\nRolling back step '%s' also failed: %s", cause, steps[i].Name, err)
+		}
+	}
+	if err := RemoveRecord(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return cause
+}
+
+// ensureState starts or stops the machine so that it is in the given state
+func ensureState(ctx context.Context, m *machine.Machine, want string) error {
+	if want == "" {
+		return nil
+	}
+	state, err := m.State(ctx)
+	if err != nil || state == want {
+		return err
+	}
+	if want == "Running" {
+		return m.Start(ctx)
+	}
+	return m.Stop(ctx)
+}
+
+// dryRunRecords holds the records saved or removed (as nil) during a dry run, in place of the files
+var dryRunRecords = map[string]*Record{}
+
+// LoadRecord loads the saved record for a machine, returning nil if there is none
+func LoadRecord(name string) (*Record, error) {
+	if record, ok := dryRunRecords[name]; ok {
+		return record, nil
+	}
+	path, err := recordPath(name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	record := new(Record)
+	if err := json.Unmarshal(b, record); err != nil {
+		return nil, fmt.Errorf("docker-workbench: could not read %s: %s", path, err)
+	}
+	return record, nil
+}
+
+// LoadRecords loads every saved record, sorted by machine name
+func LoadRecords() ([]*Record, error) {
+	configDir, err := ConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, _ := filepath.Glob(filepath.Join(configDir, "machines", "*.json"))
+	names := []string{}
+	for _, path := range paths {
+		names = append(names, strings.TrimSuffix(filepath.Base(path), ".json"))
+	}
+	for name := range dryRunRecords {
+		if _, err := os.Stat(filepath.Join(configDir, "machines", name+".json")); os.IsNotExist(err) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	records := []*Record{}
+	for _, name := range names {
+		record, err := LoadRecord(name)
+		if err != nil {
+			return nil, err
+		}
+		if record != nil {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// FindRecord returns the saved record for the machine created for a workbench directory, or nil
+// if there is none
+func FindRecord(dir string) (*Record, error) {
+	records, err := LoadRecords()
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if record.Folder == dir {
+			return record, nil
+		}
+	}
+	return nil, nil
+}
+
+// Save writes the record to the docker-workbench config directory
+func (r *Record) Save() error {
+	if DryRun {
+		saved := *r
+		saved.Completed = append([]string(nil), r.Completed...)
+		dryRunRecords[r.Name] = &saved
+		return nil
+	}
+	path, err := recordPath(r.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// RemoveRecord deletes the saved record for a machine
+func RemoveRecord(name string) error {
+	if DryRun {
+		dryRunRecords[name] = nil
+		return nil
+	}
+	path, err := recordPath(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func recordPath(name string) (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "machines", name+".json"), nil
+}
+
+func (r *Record) completed(step string) bool {
+	for _, c := range r.Completed {
+		if c == step {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Record) done(steps []Step) bool {
+	for _, step := range steps {
+		if !r.completed(step.Name) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,207 @@
+package workbench
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/justincarter/docker-workbench/machine"
+	"github.com/justincarter/docker-workbench/run"
+)
+
+func useTempConfigDir(t *testing.T) {
+	dir := t.TempDir()
+	original := ConfigDir
+	ConfigDir = func() (string, error) { return dir, nil }
+	t.Cleanup(func() { ConfigDir = original })
+}
+
+func useTestHost(t *testing.T) {
+	cpus, memory := machine.HostCPUs, machine.HostMemory
+	machine.HostCPUs = func() int { return 8 }
+	machine.HostMemory = func() int { return 16384 }
+	t.Cleanup(func() { machine.HostCPUs, machine.HostMemory = cpus, memory })
+}
+
+func newTestWorkbench(r run.Runner) *Workbench {
+	return &Workbench{
+		Machine: machine.Machine{Name: "workbench", Runner: r},
+		App:     "*",
+		Dir:     "/d/workbench",
+		Config:  &Config{ProxyImage: ProxyImage},
+	}
+}
+
+func TestCreate_SavesProgressAndResumes(t *testing.T) {
+	useTempConfigDir(t)
+	useTestHost(t)
+
+	r := run.NewFakeRunner().On("docker-machine ssh workbench docker run", run.Response{ExitCode: 125})
+	w := newTestWorkbench(r)
+	if err := w.Create(context.Background(), false); err == nil {
+		t.Fatal("expected create to fail")
+	}
+	record, _ := LoadRecord("workbench")
+	if record == nil || !reflect.DeepEqual(record.Completed, []string{"create", "env", "bootsync"}) {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+
+	r = run.NewFakeRunner().
+		On(run.VBoxManagePath()+" list vms", run.Response{Stdout: "\"workbench\" {9b2d1a7c}\n"}).
+		On("docker-machine status", run.Response{Stdout: "Stopped\n"})
+	w.Runner = r
+	if err := w.Create(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		run.VBoxManagePath() + " list vms",
+		"docker-machine status workbench",
+		"docker-machine start workbench",
+		"docker-machine ssh workbench docker rm -f docker_workbench_proxy 2> /dev/null || true",
+		"docker-machine ssh workbench docker run -d --restart=always --name=docker_workbench_proxy --label docker-workbench.name=workbench -p 80:80 -v '/var/run/docker.sock:/tmp/docker.sock:ro' justincarter/docker-workbench-proxy",
+		"docker-machine stop workbench",
+		run.VBoxManagePath() + " showvminfo workbench --machinereadable",
+		run.VBoxManagePath() + " sharedfolder add workbench --name workbench --hostpath /d/workbench",
+	}
+	if !reflect.DeepEqual(expected, r.Calls()) {
+		t.Errorf("unexpected calls: %v", r.Calls())
+	}
+
+	// a completed workbench is left alone
+	r = run.NewFakeRunner().On(run.VBoxManagePath()+" list vms", run.Response{Stdout: "\"workbench\" {9b2d1a7c}\n"})
+	w.Runner = r
+	if err := w.Create(context.Background(), false); err != nil || len(r.Calls()) != 1 {
+		t.Errorf("unexpected calls: %v", r.Calls())
+	}
+}
+
+func TestCreateSteps_ShareSkipsSharedFolders(t *testing.T) {
+	r := run.NewFakeRunner().
+		On(run.VBoxManagePath()+" showvminfo", run.Response{Stdout: "SharedFolderNameMachineMapping1=\"workbench\"\nSharedFolderPathMachineMapping1=\"/d/workbench\"\n"})
+	w := newTestWorkbench(r)
+	w.Config.SharedFolders = []SharedFolder{{Name: "data", Path: "/d/data"}}
+	steps := w.CreateSteps()
+	if err := steps[len(steps)-1].Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		run.VBoxManagePath() + " showvminfo workbench --machinereadable",
+		run.VBoxManagePath() + " sharedfolder add workbench --name data --hostpath /d/data",
+	}
+	if !reflect.DeepEqual(expected, r.Calls()) {
+		t.Errorf("unexpected calls: %v", r.Calls())
+	}
+}
+
+func TestCreate_Rollback(t *testing.T) {
+	useTempConfigDir(t)
+	useTestHost(t)
+
+	r := run.NewFakeRunner().On("docker-machine ssh", run.Response{ExitCode: 1})
+	w := newTestWorkbench(r)
+	if err := w.Create(context.Background(), true); err == nil {
+		t.Fatal("expected create to fail")
+	}
+	calls := r.Calls()
+	if calls[len(calls)-1] != "docker-machine rm -y workbench" {
+		t.Errorf("machine was not removed: %v", calls)
+	}
+	if record, _ := LoadRecord("workbench"); record != nil {
+		t.Errorf("record was not removed: %+v", record)
+	}
+}
+
+func TestCreate_ChecksResources(t *testing.T) {
+	useTempConfigDir(t)
+	useTestHost(t)
+
+	r := run.NewFakeRunner()
+	w := newTestWorkbench(r)
+	w.Env = map[string]string{"VIRTUALBOX_MEMORY_SIZE": "32768"}
+	if err := w.Create(context.Background(), false); err == nil {
+		t.Fatal("expected create to fail")
+	}
+	if calls := r.Calls(); len(calls) != 1 {
+		t.Errorf("unexpected calls: %v", calls)
+	}
+}
+
+func TestCreate_OneNativeWorkbench(t *testing.T) {
+	useTempConfigDir(t)
+	(&Record{Name: "other", Folder: "/d/other", Driver: "native"}).Save()
+
+	r := run.NewFakeRunner()
+	w := newTestWorkbench(r)
+	w.Driver = machine.Native{}
+	err := w.Create(context.Background(), false)
+	if err == nil || !strings.Contains(err.Error(), "already the native workbench 'other'") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCreate_DryRun(t *testing.T) {
+	useTempConfigDir(t)
+	useTestHost(t)
+	DryRun = true
+	t.Cleanup(func() { DryRun, dryRunRecords = false, map[string]*Record{} })
+
+	for _, driver := range []machine.Driver{machine.VirtualBox{}, machine.KVM{}, machine.Generic{}, machine.Native{}} {
+		w := newTestWorkbench(run.NewRecorder(io.Discard))
+		w.Name = "workbench-" + driver.Name()
+		w.Driver = driver
+		if err := w.Create(context.Background(), false); err != nil {
+			t.Errorf("%s: %v", driver.Name(), err)
+			continue
+		}
+		if exists, err := w.Exists(context.Background()); !exists || err != nil {
+			t.Errorf("%s: machine was not found after create: %v", driver.Name(), err)
+		}
+		if record, _ := LoadRecord(w.Name); record == nil || record.Driver != driver.Name() {
+			t.Errorf("%s: unexpected record: %+v", driver.Name(), record)
+		}
+	}
+	dir, _ := ConfigDir()
+	if paths, _ := filepath.Glob(filepath.Join(dir, "machines", "*")); len(paths) != 0 {
+		t.Errorf("records were saved during a dry run: %v", paths)
+	}
+}
+
+func TestFindRecord(t *testing.T) {
+	useTempConfigDir(t)
+
+	(&Record{Name: "dev", Folder: "/d/workbench"}).Save()
+	if record, err := FindRecord("/d/workbench"); err != nil || record == nil || record.Name != "dev" {
+		t.Errorf("unexpected record: %+v %v", record, err)
+	}
+	if record, err := FindRecord("/d/other"); err != nil || record != nil {
+		t.Errorf("unexpected record: %+v %v", record, err)
+	}
+}
+
+func TestCreateSteps_Generic(t *testing.T) {
+	w := newTestWorkbench(run.NewFakeRunner())
+	w.Driver = machine.Generic{}
+	names := []string{}
+	for _, step := range w.CreateSteps() {
+		names = append(names, step.Name+":"+step.Needs)
+	}
+	expected := []string{"create:", "env:Running", "bootsync:Running", "proxy:Running", "sharedfolder:Running"}
+	if !reflect.DeepEqual(expected, names) {
+		t.Errorf("unexpected steps: %v", names)
+	}
+}
+
+func TestCreateSteps_Native(t *testing.T) {
+	w := newTestWorkbench(run.NewFakeRunner())
+	w.Driver = machine.Native{}
+	names := []string{}
+	for _, step := range w.CreateSteps() {
+		names = append(names, step.Name)
+	}
+	if expected := []string{"create", "env", "bootsync", "proxy", "sharedfolder"}; !reflect.DeepEqual(expected, names) {
+		t.Errorf("unexpected steps: %v", names)
+	}
+}
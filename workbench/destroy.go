@@ -0,0 +1,97 @@
+package workbench
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/justincarter/docker-workbench/machine"
+)
+
+// Inventory lists what will be lost when a workbench machine is destroyed
+type Inventory struct {
+	// Running is false when the machine is stopped and its containers and volumes could not be listed
+	Running    bool
+	Containers []string
+	Volumes    []string
+}
+
+// anonymousVolume matches the generated names of volumes that were never given a name
+var anonymousVolume = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// TakeInventory lists the running containers (other than the workbench proxy) and the named
+// volumes in a workbench machine
+func TakeInventory(ctx context.Context, m *machine.Machine) (*Inventory, error) {
+	inv := new(Inventory)
+	if _, ok := driverOf(m).(machine.Host); ok {
+		// the Docker engine outlives the workbench, so only the proxy is removed
+		inv.Running = true
+		return inv, nil
+	}
+	state, err := m.State(ctx)
+	if err != nil || state != "Running" {
+		return inv, err
+	}
+	inv.Running = true
+
+	out, err := m.SSHOutput(ctx, "docker ps --format '{{.Names}}'")
+	if err != nil {
+		return inv, err
+	}
+	for _, name := range strings.Fields(string(out)) {
+		if name != ProxyContainer {
+			inv.Containers = append(inv.Containers, name)
+		}
+	}
+
+	out, err = m.SSHOutput(ctx, "docker volume ls --format '{{.Name}}'")
+	if err != nil {
+		return inv, err
+	}
+	for _, name := range strings.Fields(string(out)) {
+		if !anonymousVolume.MatchString(name) {
+			inv.Volumes = append(inv.Volumes, name)
+		}
+	}
+	return inv, nil
+}
+
+// Destroy stops a workbench machine, removes its workbench shared folder and then removes the
+// machine, its Docker CLI context and the record of how it was created. Machines whose driver
+// shares folders while they are running are not stopped. A native workbench's shared folder is
+// only removed if it is this workbench's directory, as the link is not per machine.
+func Destroy(ctx context.Context, m *machine.Machine) error {
+	if driverOf(m).ShareStopped() {
+		if err := ensureState(ctx, m, "Stopped"); err != nil {
+			return err
+		}
+	}
+	folders, err := m.SharedFolders(ctx)
+	if err != nil {
+		return err
+	}
+	path, ok := folders["workbench"]
+	if _, host := driverOf(m).(machine.Host); ok && host {
+		record, err := LoadRecord(m.Name)
+		if err != nil {
+			return err
+		}
+		ok = record != nil && record.Folder == path
+	}
+	if ok {
+		if err := m.RemoveSharedFolder(ctx, "workbench"); err != nil {
+			return err
+		}
+	}
+	if err := m.Remove(ctx); err != nil {
+		return err
+	}
+	if err := m.RemoveContext(ctx); err != nil {
+		return err
+	}
+	if err := RemoveRecord(m.Name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
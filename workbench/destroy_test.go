@@ -0,0 +1,79 @@
+package workbench
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/justincarter/docker-workbench/machine"
+	"github.com/justincarter/docker-workbench/run"
+)
+
+func TestTakeInventory(t *testing.T) {
+	r := run.NewFakeRunner().
+		On("docker-machine status", run.Response{Stdout: "Running\n"}).
+		On("docker-machine ssh workbench docker ps", run.Response{Stdout: "docker_workbench_proxy\nmyapp_myapp_1\n"}).
+		On("docker-machine ssh workbench docker volume", run.Response{Stdout: "myapp_data\n6c7ef0bd7bd52e5f6d3f7dc3dc5c6d1d1ba0d93e2a3f2d2b6b6c2b0b1c2e3f4a\n"})
+	m := &machine.Machine{Name: "workbench", Runner: r}
+
+	inv, err := TakeInventory(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Inventory{Running: true, Containers: []string{"myapp_myapp_1"}, Volumes: []string{"myapp_data"}}
+	if !reflect.DeepEqual(expected, inv) {
+		t.Errorf("unexpected inventory: %+v", inv)
+	}
+}
+
+func TestDestroy(t *testing.T) {
+	useTempConfigDir(t)
+	(&Record{Name: "workbench"}).Save()
+
+	r := run.NewFakeRunner().
+		On("docker-machine status", run.Response{Stdout: "Stopped\n"}).
+		On(run.VBoxManagePath()+" showvminfo", run.Response{Stdout: "SharedFolderNameMachineMapping1=\"workbench\"\nSharedFolderPathMachineMapping1=\"/d/workbench\"\n"})
+	m := &machine.Machine{Name: "workbench", Runner: r}
+
+	if err := Destroy(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"docker-machine status workbench",
+		run.VBoxManagePath() + " showvminfo workbench --machinereadable",
+		run.VBoxManagePath() + " sharedfolder remove workbench --name workbench",
+		"docker-machine rm -y workbench",
+		"docker context inspect workbench",
+		"docker context rm -f workbench",
+	}
+	if !reflect.DeepEqual(expected, r.Calls()) {
+		t.Errorf("unexpected calls: %v", r.Calls())
+	}
+	if record, _ := LoadRecord("workbench"); record != nil {
+		t.Error("record was not removed")
+	}
+}
+
+func TestDestroy_NativeKeepsOtherWorkbenchLink(t *testing.T) {
+	useTempConfigDir(t)
+	root := t.TempDir()
+	original := machine.NativeRoot
+	machine.NativeRoot = root
+	t.Cleanup(func() { machine.NativeRoot = original })
+	os.Symlink("/d/other", filepath.Join(root, "workbench"))
+	(&Record{Name: "workbench", Folder: "/d/workbench", Driver: "native"}).Save()
+
+	r := run.NewFakeRunner()
+	m := &machine.Machine{Name: "workbench", Driver: machine.Native{}, Runner: r}
+	if err := Destroy(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range r.Calls() {
+		if strings.HasPrefix(c, "sh -c") {
+			t.Errorf("another workbench's link was removed: %v", r.Calls())
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package workbench
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// DockerVars are the variables `docker-machine env` sets to point docker at a machine
+var DockerVars = []string{"DOCKER_TLS_VERIFY", "DOCKER_HOST", "DOCKER_CERT_PATH", "DOCKER_MACHINE_NAME"}
+
+// WorkbenchVars are the variables describing the workbench and its app
+var WorkbenchVars = []string{"WORKBENCH_NAME", "WORKBENCH_APP", "WORKBENCH_IP", "WORKBENCH_URL", "COMPOSE_PROJECT_NAME"}
+
+// Shells are the shells ShellEnv can be formatted for
+var Shells = []string{"bash", "zsh", "fish", "powershell", "cmd"}
+
+// ShellEnv returns the docker environment variables for the workbench machine, along with variables
+// describing the workbench and its app that scripts and docker-compose files can refer to
+func (w *Workbench) ShellEnv(ctx context.Context) (map[string]string, error) {
+	env, err := w.EnvOutput(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ip, err := w.IP(ctx)
+	if err != nil {
+		return nil, err
+	}
+	env["WORKBENCH_NAME"] = w.Name
+	env["WORKBENCH_IP"] = ip
+	if w.App != "*" {
+		env["WORKBENCH_APP"] = w.App
+		env["WORKBENCH_URL"] = fmt.Sprintf("http://%s.%s.nip.io/", w.App, ip)
+		env["COMPOSE_PROJECT_NAME"] = ComposeProject(w.App)
+	}
+	return env, nil
+}
+
+// DetectShell returns the shell the user is running, from $SHELL, or powershell on Windows
+func DetectShell() string {
+	shell := filepath.Base(os.Getenv("SHELL"))
+	for _, s := range Shells {
+		if shell == s {
+			return s
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return "powershell"
+	}
+	return "bash"
+}
+
+// FormatEnv returns the commands for shell that set the variables in env and unset any other
+// DockerVars and WorkbenchVars, so that stale values from another workbench do not linger. If env
+// is nil every variable is unset.
+func FormatEnv(shell string, env map[string]string) (string, error) {
+	var set func(k, v string) string
+	var unset func(k string) string
+	var comment, usage string
+	switch shell {
+	case "bash", "zsh":
+		set = func(k, v string) string {
+			return fmt.Sprintf("export %s=\"%s\"", k, escape(v, `\`, `\`, `"`, `$`, "`"))
+		}
+		unset = func(k string) string { return "unset " + k }
+		comment, usage = "#", "eval \"$(docker-workbench env%s)\""
+	case "fish":
+		set = func(k, v string) string { return fmt.Sprintf("set -gx %s \"%s\";", k, escape(v, `\`, `\`, `"`, `$`)) }
+		unset = func(k string) string { return fmt.Sprintf("set -e %s;", k) }
+		comment, usage = "#", "eval (docker-workbench env%s)"
+	case "powershell":
+		set = func(k, v string) string { return fmt.Sprintf("$Env:%s = \"%s\"", k, escape(v, "`", "`", `"`, `$`)) }
+		unset = func(k string) string { return fmt.Sprintf("Remove-Item Env:\\%s -ErrorAction SilentlyContinue", k) }
+		comment, usage = "#", "& docker-workbench env%s | Invoke-Expression"
+	case "cmd":
+		set = func(k, v string) string { return fmt.Sprintf("SET %s=%s", k, v) }
+		unset = func(k string) string { return fmt.Sprintf("SET %s=", k) }
+		comment, usage = "REM", "@FOR /f \"tokens=*\" %%i IN ('docker-workbench env%s') DO @%%i"
+	default:
+		return "", fmt.Errorf("docker-workbench: unknown shell '%s', use one of %s", shell, strings.Join(Shells, ", "))
+	}
+
+	names := make([]string, 0, len(env))
+	for k := range env {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, k := range names {
+		fmt.Fprintln(&b, set(k, env[k]))
+	}
+	for _, k := range append(DockerVars, WorkbenchVars...) {
+		if _, ok := env[k]; !ok {
+			fmt.Fprintln(&b, unset(k))
+		}
+	}
+
+	args := ""
+	if shell != DetectShell() {
+		args += " --shell " + shell
+	}
+	if env == nil {
+		args += " --unset"
+	}
+	fmt.Fprintf(&b, "%s Run this command to configure your shell:\n", comment)
+	fmt.Fprintf(&b, "%s "+usage+"\n", comment, args)
+	return b.String(), nil
+}
+
+// escape prefixes each of chars in s with esc
+func escape(s, esc string, chars ...string) string {
+	pairs := make([]string, 0, len(chars)*2)
+	for _, c := range chars {
+		pairs = append(pairs, c, esc+c)
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
@@ -0,0 +1,70 @@
+package workbench
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/justincarter/docker-workbench/run"
+)
+
+func TestShellEnv(t *testing.T) {
+	r := run.NewFakeRunner().
+		On("docker-machine env", run.Response{Stdout: "export DOCKER_HOST=\"tcp://192.168.99.100:2376\"\nexport DOCKER_MACHINE_NAME=\"workbench\"\n"}).
+		On("docker-machine ip", run.Response{Stdout: "192.168.99.100\n"})
+	w := newTestWorkbench(r)
+	w.App = "My.App"
+
+	env, err := w.ShellEnv(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"DOCKER_HOST":          "tcp://192.168.99.100:2376",
+		"DOCKER_MACHINE_NAME":  "workbench",
+		"WORKBENCH_NAME":       "workbench",
+		"WORKBENCH_IP":         "192.168.99.100",
+		"WORKBENCH_APP":        "My.App",
+		"WORKBENCH_URL":        "http://My.App.192.168.99.100.nip.io/",
+		"COMPOSE_PROJECT_NAME": "myapp",
+	}
+	if !reflect.DeepEqual(expected, env) {
+		t.Errorf("unexpected env: %v", env)
+	}
+}
+
+func TestFormatEnv(t *testing.T) {
+	env := map[string]string{"DOCKER_HOST": "tcp://10.0.0.5:2376", "WORKBENCH_NAME": `a"b$c`}
+	cases := map[string][]string{
+		"bash":       {`export DOCKER_HOST="tcp://10.0.0.5:2376"`, `export WORKBENCH_NAME="a\"b\$c"`, "unset DOCKER_CERT_PATH", "unset WORKBENCH_URL"},
+		"fish":       {`set -gx WORKBENCH_NAME "a\"b\$c";`, "set -e DOCKER_CERT_PATH;"},
+		"powershell": {`$Env:WORKBENCH_NAME = "a` + "`\"b`$" + `c"`, `Remove-Item Env:\DOCKER_CERT_PATH -ErrorAction SilentlyContinue`},
+		"cmd":        {`SET WORKBENCH_NAME=a"b$c`, "SET DOCKER_CERT_PATH=", "REM @FOR /f \"tokens=*\" %i IN ('docker-workbench env --shell cmd') DO @%i"},
+	}
+	for shell, lines := range cases {
+		out, err := FormatEnv(shell, env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range lines {
+			if !strings.Contains(out, line+"\n") {
+				t.Errorf("%s output is missing %s:\n%s", shell, line, out)
+			}
+		}
+	}
+
+	if _, err := FormatEnv("tcsh", env); err == nil {
+		t.Error("expected an error for an unknown shell")
+	}
+}
+
+func TestFormatEnv_Unset(t *testing.T) {
+	out, _ := FormatEnv("bash", nil)
+	if strings.Contains(out, "export") || !strings.Contains(out, "unset DOCKER_HOST\n") || !strings.Contains(out, "unset COMPOSE_PROJECT_NAME\n") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	if !strings.Contains(out, "--unset") {
+		t.Errorf("usage should include --unset:\n%s", out)
+	}
+}
@@ -0,0 +1,44 @@
+package workbench
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+
+	"github.com/justincarter/docker-workbench/run"
+)
+
+// AppDir returns the directory of the workbench app, or the workbench directory if there is no app
+func (w *Workbench) AppDir() string {
+	if w.App == "*" {
+		return w.Dir
+	}
+	return filepath.Join(w.Dir, w.App)
+}
+
+// Exec runs a command from the app directory with the ShellEnv of the workbench, connected to the
+// terminal. The environment of docker-workbench itself is left untouched, and any DockerVars and
+// WorkbenchVars that do not apply are cleared so that the command cannot use another machine.
+func (w *Workbench) Exec(ctx context.Context, command string, args ...string) error {
+	env, err := w.ShellEnv(ctx)
+	if err != nil {
+		return err
+	}
+	for _, k := range append(DockerVars, WorkbenchVars...) {
+		if _, ok := env[k]; !ok {
+			env[k] = ""
+		}
+	}
+	vars := []string{}
+	for k, v := range env {
+		vars = append(vars, k+"="+v)
+	}
+	sort.Strings(vars)
+
+	r := w.Runner
+	if r == nil {
+		r = run.Default
+	}
+	ctx = run.Interactive(run.WithDir(run.WithEnv(ctx, vars...), w.AppDir()))
+	return r.Run(ctx, command, args...)
+}
@@ -0,0 +1,55 @@
+package workbench
+
+import (
+	"context"
+	"testing"
+
+	"github.com/justincarter/docker-workbench/machine"
+	"github.com/justincarter/docker-workbench/run"
+)
+
+func TestExec(t *testing.T) {
+	r := run.NewFakeRunner().
+		On("docker-machine env", run.Response{Stdout: "export DOCKER_HOST=\"tcp://192.168.99.100:2376\"\n"}).
+		On("docker-machine ip", run.Response{Stdout: "192.168.99.100\n"})
+	w := newTestWorkbench(r)
+	w.App = "myapp"
+
+	if err := w.Exec(context.Background(), "docker-compose", "up", "-d"); err != nil {
+		t.Fatal(err)
+	}
+	calls := r.Calls()
+	last := r.Call(len(calls) - 1)
+	if last.String() != "docker-compose up -d" || last.Dir != "/d/workbench/myapp" {
+		t.Errorf("unexpected call: %s in %s", last, last.Dir)
+	}
+	env := map[string]bool{}
+	for _, e := range last.Env {
+		env[e] = true
+	}
+	for _, e := range []string{"DOCKER_HOST=tcp://192.168.99.100:2376", "DOCKER_CERT_PATH=", "COMPOSE_PROJECT_NAME=myapp", "WORKBENCH_URL=http://myapp.192.168.99.100.nip.io/"} {
+		if !env[e] {
+			t.Errorf("%s was not set: %v", e, last.Env)
+		}
+	}
+}
+
+func TestExec_Native(t *testing.T) {
+	r := run.NewFakeRunner()
+	w := newTestWorkbench(r)
+	w.Driver = machine.Native{}
+
+	if err := w.Exec(context.Background(), "docker", "ps"); err != nil {
+		t.Fatal(err)
+	}
+	last := r.Call(len(r.Calls()) - 1)
+	if last.Dir != "/d/workbench" {
+		t.Errorf("unexpected directory: %s", last.Dir)
+	}
+	for _, e := range last.Env {
+		if e == "DOCKER_HOST=" {
+			return
+		}
+	}
+	t.Errorf("DOCKER_HOST was not cleared: %v", last.Env)
+}
@@ -0,0 +1,375 @@
+package workbench
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/justincarter/docker-workbench/run"
+)
+
+// Exchange is a request through the proxy and the response the app gave, as captured by an
+// Inspector. Status is 0 until the response has started, or for good if the exchange was aborted
+// first, and Duration is 0 until it has finished.
+type Exchange struct {
+	ID       int           `json:"id"`
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+	Method   string        `json:"method"`
+	// URL is the URL the client requested from the proxy
+	URL              string      `json:"url"`
+	RemoteAddr       string      `json:"remote_addr"`
+	RequestHeader    http.Header `json:"request_header"`
+	RequestBody      []byte      `json:"request_body"`
+	RequestTruncated bool        `json:"request_truncated"`
+
+	Status         int         `json:"status"`
+	ResponseHeader http.Header `json:"response_header"`
+	// ResponseBody is decompressed if the app gzipped it, so that it can be read
+	ResponseBody []byte `json:"response_body"`
+	// ResponseSize is the size of the whole response body, which may be more than was captured
+	ResponseSize      int64 `json:"response_size"`
+	ResponseTruncated bool  `json:"response_truncated"`
+	// Aborted is set if the handler panicked or the client went away before the response finished
+	Aborted bool `json:"aborted"`
+}
+
+// Path returns the path and query of the requested URL
+func (e *Exchange) Path() string {
+	u, err := url.Parse(e.URL)
+	if err != nil {
+		return e.URL
+	}
+	return u.RequestURI()
+}
+
+// Curl returns a curl command that repeats the request through the proxy. A truncated request
+// body is included as far as it was captured.
+func (e *Exchange) Curl() string {
+	args := []string{"curl"}
+	if e.Method != "GET" {
+		args = append(args, "-X", e.Method)
+	}
+	keys := make([]string, 0, len(e.RequestHeader))
+	for k := range e.RequestHeader {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		switch k {
+		case "Content-Length", "Connection":
+			continue
+		case "Accept-Encoding":
+			// let curl decompress the response rather than print it compressed
+			args = append(args, "--compressed")
+			continue
+		}
+		for _, v := range e.RequestHeader[k] {
+			args = append(args, "-H", run.Quote(k+": "+v))
+		}
+	}
+	if len(e.RequestBody) > 0 {
+		args = append(args, "--data-binary", run.Quote(string(e.RequestBody)))
+	}
+	if strings.HasPrefix(e.URL, "https:") {
+		// the proxy's certificates are issued by the local CA
+		args = append(args, "--insecure")
+	}
+	return strings.Join(append(args, run.Quote(e.URL)), " ")
+}
+
+// Inspector keeps the most recent exchanges through the proxy, with bodies captured up to a size
+// limit, and serves a web page for browsing them
+type Inspector struct {
+	history   int
+	bodyLimit int
+
+	mu     sync.Mutex
+	nextID int
+	// exchanges is a ring buffer holding the exchange with each ID at (ID-1) % history
+	exchanges []*Exchange
+}
+
+// NewInspector returns an Inspector keeping the last history exchanges, with request and
+// response bodies captured up to bodyLimit bytes each
+func NewInspector(history, bodyLimit int) *Inspector {
+	return &Inspector{history: history, bodyLimit: bodyLimit, nextID: 1}
+}
+
+// add keeps e in place of the oldest exchange once history is full
+func (i *Inspector) add(e *Exchange) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	e.ID = i.nextID
+	i.nextID++
+	if pos := (e.ID - 1) % i.history; pos < len(i.exchanges) {
+		i.exchanges[pos] = e
+	} else {
+		i.exchanges = append(i.exchanges, e)
+	}
+}
+
+// Exchanges returns copies of the kept exchanges, newest first, whose path contains path and
+// whose status matches status, e.g. 404, 4xx, pending or aborted. Empty filters match every
+// exchange.
+func (i *Inspector) Exchanges(path, status string) []Exchange {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	result := []Exchange{}
+	for n := 0; n < len(i.exchanges); n++ {
+		e := i.exchanges[(i.nextID-2-n)%i.history]
+		if strings.Contains(e.Path(), path) && matchStatus(e, status) {
+			result = append(result, *e)
+		}
+	}
+	return result
+}
+
+// Exchange returns a copy of the exchange with the given ID, if it is still kept
+func (i *Inspector) Exchange(id int) (Exchange, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if id < 1 || id >= i.nextID || i.nextID-id > len(i.exchanges) {
+		return Exchange{}, false
+	}
+	return *i.exchanges[(id-1)%i.history], true
+}
+
+// matchStatus reports whether the status of e matches a filter of an exact code, a class such as
+// 5xx, pending for responses that have not started, or aborted
+func matchStatus(e *Exchange, filter string) bool {
+	status := e.Status
+	filter = strings.ToLower(strings.TrimSpace(filter))
+	switch {
+	case filter == "":
+		return true
+	case filter == "pending":
+		return status == 0 && !e.Aborted
+	case filter == "aborted":
+		return e.Aborted
+	case len(filter) == 3 && strings.HasSuffix(filter, "xx"):
+		return status != 0 && strconv.Itoa(status/100) == filter[:1]
+	}
+	return strconv.Itoa(status) == filter
+}
+
+// Middleware returns next wrapped so that each exchange through it is captured. The response
+// writer passed on still supports flushing and hijacking, so streamed responses and WebSocket
+// upgrades are unaffected; the frames of an upgraded connection are not captured. A panic in
+// next is recorded as an aborted exchange and then carries on up to the server.
+func (i *Inspector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		e := &Exchange{
+			Time:          time.Now(),
+			Method:        r.Method,
+			URL:           scheme + "://" + r.Host + r.URL.RequestURI(),
+			RemoteAddr:    r.RemoteAddr,
+			RequestHeader: r.Header.Clone(),
+		}
+		i.add(e)
+
+		reqBody := &limitedBuffer{limit: i.bodyLimit}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = &teeBody{ReadCloser: r.Body, w: reqBody}
+		}
+		rec := &recorder{ResponseWriter: rw, body: limitedBuffer{limit: i.bodyLimit}, inspector: i, exchange: e}
+		defer func() {
+			i.mu.Lock()
+			defer i.mu.Unlock()
+			e.Duration = time.Since(e.Time)
+			// net/http sends a 200 for a handler that writes nothing, but the proxy always writes
+			// a status unless it panicked
+			e.Aborted = !rec.wrote || (!rec.hijacked && r.Context().Err() != nil)
+			if rec.hijacked {
+				e.ResponseHeader = rw.Header().Clone()
+			}
+			e.RequestBody, e.RequestTruncated = reqBody.Bytes(), reqBody.truncated
+			e.ResponseBody, e.ResponseTruncated = rec.body.Bytes(), rec.body.truncated
+			if strings.EqualFold(e.ResponseHeader.Get("Content-Encoding"), "gzip") {
+				e.ResponseBody, e.ResponseTruncated = gunzip(e.ResponseBody, i.bodyLimit)
+			}
+			e.ResponseSize = rec.size
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// recorder captures the status, headers and body of a response as it is written
+type recorder struct {
+	http.ResponseWriter
+	body      limitedBuffer
+	inspector *Inspector
+	exchange  *Exchange
+	size      int64
+	wrote     bool
+	// hijacked is set once the connection has been taken over, e.g. for a WebSocket, which
+	// happens before the proxy has copied the response headers
+	hijacked bool
+}
+
+func (rec *recorder) WriteHeader(code int) {
+	// informational responses other than 101 are followed by the real one
+	if !rec.wrote && (code >= 200 || code == http.StatusSwitchingProtocols) {
+		rec.wrote = true
+		rec.inspector.mu.Lock()
+		rec.exchange.Status = code
+		rec.exchange.ResponseHeader = rec.Header().Clone()
+		rec.inspector.mu.Unlock()
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if !rec.wrote {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(b)
+	n, err := rec.ResponseWriter.Write(b)
+	rec.size += int64(n)
+	return n, err
+}
+
+func (rec *recorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rec *recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	rec.inspector.mu.Lock()
+	rec.wrote, rec.hijacked = true, true
+	rec.exchange.Status = http.StatusSwitchingProtocols
+	rec.inspector.mu.Unlock()
+	return h.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying response writer
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the rest
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); len(p) > room {
+		b.truncated = true
+		p = p[:room]
+	}
+	return b.Buffer.Write(p)
+}
+
+// gunzip decompresses as much of a captured gzip body as it can, up to limit bytes. truncated is
+// set if the body could not be decompressed in full, e.g. because it was truncated when captured.
+func gunzip(b []byte, limit int) (body []byte, truncated bool) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return b, true
+	}
+	out := &limitedBuffer{limit: limit}
+	_, err = io.Copy(out, zr)
+	return out.Bytes(), err != nil || out.truncated
+}
+
+// teeBody copies a request body to w as the proxy reads it
+type teeBody struct {
+	io.ReadCloser
+	w io.Writer
+}
+
+func (t *teeBody) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	t.w.Write(p[:n])
+	return n, err
+}
+
+// Handler returns the inspector web page and the JSON API it uses:
+//
+//	GET /api/exchanges?path=&status=   the kept exchanges, newest first, without headers or bodies
+//	GET /api/exchanges/ID              one exchange
+//	GET /api/exchanges/ID/curl         a curl command repeating the request
+//
+// Requests for any Host other than localhost are forbidden, so that a web page on another site
+// cannot read the captured cookies and bodies by pointing its own host name at 127.0.0.1.
+func (i *Inspector) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(rw, r)
+			return
+		}
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(rw, inspectorPage)
+	})
+	mux.HandleFunc("/api/exchanges", func(rw http.ResponseWriter, r *http.Request) {
+		exchanges := i.Exchanges(r.URL.Query().Get("path"), r.URL.Query().Get("status"))
+		for n := range exchanges {
+			e := &exchanges[n]
+			e.RequestHeader, e.RequestBody, e.ResponseHeader, e.ResponseBody = nil, nil, nil, nil
+		}
+		writeJSON(rw, exchanges)
+	})
+	mux.HandleFunc("/api/exchanges/", func(rw http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/exchanges/"), "/")
+		id, err := strconv.Atoi(parts[0])
+		e, ok := i.Exchange(id)
+		if err != nil || !ok || len(parts) > 2 || (len(parts) == 2 && parts[1] != "curl") {
+			http.NotFound(rw, r)
+			return
+		}
+		if len(parts) == 2 {
+			rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			io.WriteString(rw, e.Curl()+"\n")
+			return
+		}
+		writeJSON(rw, e)
+	})
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if !localHost(r.Host) {
+			http.Error(rw, "The inspector can only be browsed at localhost", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(rw, r)
+	})
+}
+
+// localHost reports whether a Host header names this computer by its loopback address
+func localHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	switch strings.ToLower(host) {
+	case "localhost", "127.0.0.1", "::1", "[::1]":
+		return true
+	}
+	return false
+}
+
+// writeJSON writes v as the JSON response
+func writeJSON(rw http.ResponseWriter, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(rw).Encode(v)
+}
@@ -0,0 +1,137 @@
+package workbench
+
+// inspectorPage is the web page served by Inspector.Handler, which polls the JSON API for the
+// latest exchanges
+const inspectorPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>docker-workbench proxy inspector</title>
+<style>
+body { margin: 0; font: 13px -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; color: #222; }
+header { display: flex; gap: 8px; align-items: center; padding: 8px 12px; background: #2b3a4a; color: #fff; }
+header h1 { font-size: 15px; margin: 0 12px 0 0; }
+header input { padding: 4px 6px; border: 0; border-radius: 3px; }
+main { display: flex; height: calc(100vh - 42px); }
+#list { flex: 1; overflow: auto; border-right: 1px solid #ccc; }
+#detail { flex: 1; overflow: auto; padding: 0 12px; }
+table { width: 100%; border-collapse: collapse; }
+th, td { text-align: left; padding: 3px 8px; white-space: nowrap; }
+th { position: sticky; top: 0; background: #eee; }
+tbody tr { cursor: pointer; border-bottom: 1px solid #eee; }
+tbody tr:hover { background: #f4f8fc; }
+tbody tr.selected { background: #dbe9f7; }
+td.path { max-width: 400px; overflow: hidden; text-overflow: ellipsis; }
+.s2 { color: #2a7d2a; } .s3 { color: #8a6d00; } .s4, .s5 { color: #c0392b; } .s0 { color: #888; } .aborted { color: #c0392b; }
+pre { background: #f6f6f6; padding: 8px; overflow: auto; white-space: pre-wrap; word-break: break-all; }
+h2 { font-size: 14px; margin: 16px 0 6px; }
+.note { color: #888; }
+button { padding: 4px 10px; }
+</style>
+</head>
+<body>
+<header>
+<h1>Proxy inspector</h1>
+<input id="path" placeholder="Path contains" size="30">
+<input id="status" placeholder="Status, e.g. 404, 5xx or aborted" size="26">
+<label><input id="live" type="checkbox" checked> Live</label>
+</header>
+<main>
+<div id="list">
+<table>
+<thead><tr><th>Time</th><th>Method</th><th>Status</th><th>Path</th><th>Host</th><th>Duration</th><th>Size</th></tr></thead>
+<tbody id="exchanges"></tbody>
+</table>
+</div>
+<div id="detail"><p class="note">Select a request to see its details.</p></div>
+</main>
+<script>
+var selected = null;
+
+function text(s) {
+	var d = document.createElement("div");
+	d.textContent = s;
+	return d.innerHTML;
+}
+
+function decode(b64) {
+	if (!b64) return "";
+	var bin = atob(b64), bytes = new Uint8Array(bin.length);
+	for (var i = 0; i < bin.length; i++) bytes[i] = bin.charCodeAt(i);
+	var s = new TextDecoder().decode(bytes);
+	try { return JSON.stringify(JSON.parse(s), null, 2); } catch (e) { return s; }
+}
+
+function duration(ns) {
+	if (!ns) return "";
+	var ms = ns / 1e6;
+	return ms < 1000 ? ms.toFixed(1) + " ms" : (ms / 1000).toFixed(2) + " s";
+}
+
+function headers(h) {
+	return Object.keys(h || {}).sort().map(function (k) {
+		return h[k].map(function (v) { return k + ": " + v; }).join("\n");
+	}).join("\n");
+}
+
+function body(b64, truncated) {
+	if (!b64) return '<p class="note">No body</p>';
+	return "<pre>" + text(decode(b64)) + "</pre>" + (truncated ? '<p class="note">Truncated</p>' : "");
+}
+
+function refresh() {
+	var q = "?path=" + encodeURIComponent(document.getElementById("path").value) +
+		"&status=" + encodeURIComponent(document.getElementById("status").value);
+	fetch("api/exchanges" + q).then(function (r) { return r.json(); }).then(function (list) {
+		document.getElementById("exchanges").innerHTML = list.map(function (e) {
+			var u = new URL(e.url);
+			return '<tr data-id="' + e.id + '"' + (e.id === selected ? ' class="selected"' : "") + ">" +
+				"<td>" + new Date(e.time).toLocaleTimeString() + "</td>" +
+				"<td>" + text(e.method) + "</td>" +
+				'<td class="' + (e.aborted && !e.status ? "aborted" : "s" + String(e.status).charAt(0)) + '">' + (e.status || (e.aborted ? "aborted" : "…")) + "</td>" +
+				'<td class="path" title="' + text(u.pathname + u.search) + '">' + text(u.pathname + u.search) + "</td>" +
+				"<td>" + text(u.host) + "</td>" +
+				"<td>" + duration(e.duration) + "</td>" +
+				"<td>" + (e.status ? e.response_size : "") + "</td></tr>";
+		}).join("");
+	});
+}
+
+function show(id) {
+	selected = id;
+	fetch("api/exchanges/" + id).then(function (r) { return r.json(); }).then(function (e) {
+		document.getElementById("detail").innerHTML =
+			"<h2>" + text(e.method + " " + e.url) + "</h2>" +
+			'<p>' + (e.status || (e.aborted ? "Aborted" : "Pending")) + (e.status && e.aborted ? " (aborted)" : "") + " from " + text(e.remote_addr) + " " + duration(e.duration) +
+			' <button id="curl">Copy as curl</button></p>' +
+			"<h2>Request headers</h2><pre>" + text(headers(e.request_header)) + "</pre>" +
+			"<h2>Request body</h2>" + body(e.request_body, e.request_truncated) +
+			"<h2>Response headers</h2><pre>" + text(headers(e.response_header)) + "</pre>" +
+			"<h2>Response body</h2>" + body(e.response_body, e.response_truncated);
+		document.getElementById("curl").onclick = function () {
+			fetch("api/exchanges/" + id + "/curl").then(function (r) { return r.text(); }).then(function (cmd) {
+				navigator.clipboard.writeText(cmd.trim()).then(function () {
+					document.getElementById("curl").textContent = "Copied";
+				}, function () {
+					window.prompt("Copy the curl command", cmd.trim());
+				});
+			});
+		};
+	});
+	refresh();
+}
+
+document.getElementById("exchanges").onclick = function (ev) {
+	var tr = ev.target.closest("tr");
+	if (tr) show(Number(tr.dataset.id));
+};
+document.getElementById("path").oninput = refresh;
+document.getElementById("status").oninput = refresh;
+setInterval(function () {
+	if (document.getElementById("live").checked) refresh();
+}, 1000);
+refresh();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,326 @@
+package workbench
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// inspectedProxy starts the proxy for myapp with the inspector in front of it
+func inspectedProxy(t *testing.T, inspector *Inspector) *httptest.Server {
+	t.Helper()
+	w := newTestWorkbench(nil)
+	w.App = "myapp"
+	proxy := httptest.NewServer(inspector.Middleware(w.proxyHandler("127.0.0.1", ProxyOptions{})))
+	t.Cleanup(proxy.Close)
+	return proxy
+}
+
+func TestInspector_Capture(t *testing.T) {
+	useTestBackend(t, func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rw.Header().Set("Content-Type", "text/plain")
+		rw.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(rw, "got %s", body)
+	})
+	inspector := NewInspector(10, 8)
+	proxy := inspectedProxy(t, inspector)
+
+	req, _ := http.NewRequest("POST", proxy.URL+"/items?x=1", strings.NewReader("name=one"))
+	req.Host = "myapp.192.168.0.10.nip.io:8080"
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	exchanges := inspector.Exchanges("", "")
+	if len(exchanges) != 1 {
+		t.Fatalf("expected 1 exchange, got %d", len(exchanges))
+	}
+	e := exchanges[0]
+	if e.ID != 1 || e.Method != "POST" || e.URL != "http://myapp.192.168.0.10.nip.io:8080/items?x=1" || e.Path() != "/items?x=1" {
+		t.Errorf("unexpected request: %d %s %s %s", e.ID, e.Method, e.URL, e.Path())
+	}
+	if string(e.RequestBody) != "name=one" || e.RequestTruncated || e.RequestHeader.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		t.Errorf("unexpected request body: %q %v %v", e.RequestBody, e.RequestTruncated, e.RequestHeader)
+	}
+	if e.Status != http.StatusCreated || e.ResponseHeader.Get("Content-Type") != "text/plain" {
+		t.Errorf("unexpected response: %d %v", e.Status, e.ResponseHeader)
+	}
+	if string(e.ResponseBody) != "got name" || !e.ResponseTruncated || e.ResponseSize != 12 || e.Duration <= 0 {
+		t.Errorf("unexpected response body: %q %v %d %s", e.ResponseBody, e.ResponseTruncated, e.ResponseSize, e.Duration)
+	}
+}
+
+func TestInspector_Gzip(t *testing.T) {
+	useTestBackend(t, func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(rw)
+		io.WriteString(zw, `{"ok":true}`)
+		zw.Close()
+	})
+	inspector := NewInspector(10, 1024)
+	proxy := inspectedProxy(t, inspector)
+	proxyGet(t, proxy, "myapp.192.168.0.10.nip.io:8080", "/")
+
+	e, ok := inspector.Exchange(1)
+	if !ok || string(e.ResponseBody) != `{"ok":true}` || e.ResponseTruncated {
+		t.Errorf("gzip body was not decompressed: %q %v", e.ResponseBody, e.ResponseTruncated)
+	}
+}
+
+func TestInspector_History(t *testing.T) {
+	useTestBackend(t, nil)
+	inspector := NewInspector(3, 1024)
+	proxy := inspectedProxy(t, inspector)
+	for i := 1; i <= 5; i++ {
+		proxyGet(t, proxy, "myapp.192.168.0.10.nip.io:8080", fmt.Sprintf("/%d", i))
+	}
+
+	paths := []string{}
+	for _, e := range inspector.Exchanges("", "") {
+		paths = append(paths, e.Path())
+	}
+	if strings.Join(paths, " ") != "/5 /4 /3" {
+		t.Errorf("unexpected exchanges kept: %v", paths)
+	}
+	if _, ok := inspector.Exchange(2); ok {
+		t.Error("dropped exchange 2 was found")
+	}
+	if e, ok := inspector.Exchange(4); !ok || e.Path() != "/4" {
+		t.Errorf("unexpected exchange 4: %v %s", ok, e.Path())
+	}
+}
+
+func TestInspector_Handler(t *testing.T) {
+	useTestBackend(t, func(rw http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			http.NotFound(rw, r)
+			return
+		}
+		io.WriteString(rw, "ok")
+	})
+	inspector := NewInspector(10, 1024)
+	proxy := inspectedProxy(t, inspector)
+	for _, path := range []string{"/", "/missing", "/api/users", "/api/missing"} {
+		proxyGet(t, proxy, "myapp.192.168.0.10.nip.io:8080", path)
+	}
+	ui := httptest.NewServer(inspector.Handler())
+	defer ui.Close()
+
+	filters := map[string]string{
+		"":                       "/api/missing /api/users /missing /",
+		"?path=api":              "/api/missing /api/users",
+		"?status=404":            "/api/missing /missing",
+		"?status=2xx&path=users": "/api/users",
+	}
+	for query, expected := range filters {
+		resp, err := http.Get(ui.URL + "/api/exchanges" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var exchanges []Exchange
+		json.NewDecoder(resp.Body).Decode(&exchanges)
+		resp.Body.Close()
+		paths := []string{}
+		for _, e := range exchanges {
+			paths = append(paths, e.Path())
+			if e.ResponseBody != nil || e.RequestHeader != nil {
+				t.Errorf("list included headers or bodies: %v", e)
+			}
+		}
+		if strings.Join(paths, " ") != expected {
+			t.Errorf("unexpected exchanges for %q: %v", query, paths)
+		}
+	}
+
+	resp, err := http.Get(ui.URL + "/api/exchanges/3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e Exchange
+	json.NewDecoder(resp.Body).Decode(&e)
+	resp.Body.Close()
+	if e.ID != 3 || string(e.ResponseBody) != "ok" {
+		t.Errorf("unexpected exchange: %d %q", e.ID, e.ResponseBody)
+	}
+
+	resp, _ = http.Get(ui.URL + "/api/exchanges/3/curl")
+	curl, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.HasPrefix(string(curl), "curl ") || !strings.HasSuffix(string(curl), " http://myapp.192.168.0.10.nip.io:8080/api/users\n") {
+		t.Errorf("unexpected curl command: %s", curl)
+	}
+
+	for _, path := range []string{"/api/exchanges/99", "/api/exchanges/x", "/api/exchanges/3/other", "/other"} {
+		if resp, _ := http.Get(ui.URL + path); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected 404 for %s, got %d", path, resp.StatusCode)
+		}
+	}
+	if resp, _ := http.Get(ui.URL + "/"); resp.StatusCode != 200 || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("unexpected inspector page: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestInspector_HandlerHost(t *testing.T) {
+	ui := httptest.NewServer(NewInspector(10, 1024).Handler())
+	defer ui.Close()
+	_, port, _ := net.SplitHostPort(ui.Listener.Addr().String())
+
+	hosts := map[string]int{
+		"localhost:" + port:       http.StatusOK,
+		"127.0.0.1:" + port:       http.StatusOK,
+		"[::1]:" + port:           http.StatusOK,
+		"LOCALHOST":               http.StatusOK,
+		"rebound.example:" + port: http.StatusForbidden,
+		"192.168.0.10:" + port:    http.StatusForbidden,
+	}
+	for host, expected := range hosts {
+		req, _ := http.NewRequest("GET", ui.URL+"/api/exchanges", nil)
+		req.Host = host
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != expected {
+			t.Errorf("expected %d for Host %s, got %d", expected, host, resp.StatusCode)
+		}
+	}
+}
+
+func TestInspector_Streams(t *testing.T) {
+	received := make(chan struct{})
+	useTestBackend(t, func(rw http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "" {
+			websocketEcho(rw, r)
+			return
+		}
+		rw.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(rw, "data: 1\n\n")
+		rw.(http.Flusher).Flush()
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+		}
+	})
+	inspector := NewInspector(10, 1024)
+	proxy := inspectedProxy(t, inspector)
+
+	req, _ := http.NewRequest("GET", proxy.URL+"/events", nil)
+	req.Host = "myapp.192.168.0.10.nip.io:8080"
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+	if line != "data: 1\n" {
+		t.Errorf("unexpected event: %q", line)
+	}
+	if e, _ := inspector.Exchange(1); e.Status != 200 || e.Duration != 0 {
+		t.Errorf("streaming exchange should have started but not finished: %d %s", e.Status, e.Duration)
+	}
+	close(received)
+	resp.Body.Close()
+
+	conn, err := net.Dial("tcp", proxy.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprintf(conn, "GET /live HTTP/1.1\r\nHost: myapp.192.168.0.10.nip.io:8080\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	r := bufio.NewReader(conn)
+	if resp, err := http.ReadResponse(r, nil); err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("upgrade failed: %v %v", resp, err)
+	}
+	writeFrame(conn, "hello", true)
+	if echo, err := readFrame(r); err != nil || echo != "echo: hello" {
+		t.Errorf("unexpected echo: %q %v", echo, err)
+	}
+	if e, _ := inspector.Exchange(2); e.Status != http.StatusSwitchingProtocols {
+		t.Errorf("unexpected status for the WebSocket: %d", e.Status)
+	}
+}
+
+func TestInspector_Aborted(t *testing.T) {
+	inspector := NewInspector(10, 1024)
+	server := httptest.NewServer(inspector.Middleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})))
+	defer server.Close()
+
+	if resp, err := http.Get(server.URL + "/crash"); err == nil {
+		resp.Body.Close()
+		t.Fatal("expected the aborted request to fail")
+	}
+	e, _ := inspector.Exchange(1)
+	if e.Status != 0 || !e.Aborted || e.Duration == 0 {
+		t.Errorf("exchange was not recorded as aborted: %d %v %s", e.Status, e.Aborted, e.Duration)
+	}
+	if len(inspector.Exchanges("", "aborted")) != 1 || len(inspector.Exchanges("", "pending")) != 0 || len(inspector.Exchanges("", "200")) != 0 {
+		t.Error("unexpected exchanges for the aborted filters")
+	}
+}
+
+func TestMatchStatus(t *testing.T) {
+	matches := []struct {
+		status  int
+		aborted bool
+		filter  string
+		match   bool
+	}{
+		{404, false, "", true},
+		{404, false, "404", true},
+		{404, false, "4xx", true},
+		{404, false, "4XX", true},
+		{500, false, "4xx", false},
+		{200, false, "201", false},
+		{0, false, "pending", true},
+		{200, false, "pending", false},
+		{0, false, "0xx", false},
+		{0, true, "pending", false},
+		{0, true, "aborted", true},
+		{200, true, "aborted", true},
+		{200, false, "aborted", false},
+	}
+	for _, m := range matches {
+		if matchStatus(&Exchange{Status: m.status, Aborted: m.aborted}, m.filter) != m.match {
+			t.Errorf("expected matchStatus(%d, aborted %v, %q) to be %v", m.status, m.aborted, m.filter, m.match)
+		}
+	}
+}
+
+func TestExchangeCurl(t *testing.T) {
+	e := Exchange{
+		Method: "POST",
+		URL:    "https://myapp.192.168.0.10.nip.io:8443/login?next=/",
+		RequestHeader: http.Header{
+			"Content-Type":    {"application/json"},
+			"Content-Length":  {"15"},
+			"Accept-Encoding": {"gzip"},
+		},
+		RequestBody: []byte(`{"user":"o'k"}`),
+	}
+	expected := `curl -X POST --compressed -H 'Content-Type: application/json' --data-binary '{"user":"o'\''k"}' --insecure 'https://myapp.192.168.0.10.nip.io:8443/login?next=/'`
+	if curl := e.Curl(); curl != expected {
+		t.Errorf("unexpected curl command:\n%s\nexpected:\n%s", curl, expected)
+	}
+
+	e = Exchange{Method: "GET", URL: "http://myapp.192.168.0.10.nip.io:8080/", RequestHeader: http.Header{}}
+	if curl := e.Curl(); curl != "curl http://myapp.192.168.0.10.nip.io:8080/" {
+		t.Errorf("unexpected curl command: %s", curl)
+	}
+}
@@ -0,0 +1,123 @@
+package workbench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/justincarter/docker-workbench/machine"
+	"github.com/justincarter/docker-workbench/run"
+)
+
+// ComposeFiles are the file names docker-compose looks for in an app directory
+var ComposeFiles = []string{"docker-compose.yml", "docker-compose.yaml", "compose.yml", "compose.yaml"}
+
+// Summary describes a workbench machine found by List
+type Summary struct {
+	Name  string `json:"name"`
+	Dir   string `json:"dir"`
+	State string `json:"state"`
+	IP    string `json:"ip,omitempty"`
+	Apps  []App  `json:"apps"`
+}
+
+// App is an app directory inside a workbench
+type App struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+// List returns every VirtualBox VM that is a workbench, identified by its workbench shared folder,
+// followed by the workbench machines created with other drivers, along with the apps in each
+// directory. VirtualBox VMs are skipped if VirtualBox is not installed. r runs the commands, using
+// run.Default if nil.
+func List(ctx context.Context, r run.Runner) ([]Summary, error) {
+	machines, err := machine.List(ctx, r)
+	if err != nil && !errors.Is(err, exec.ErrNotFound) {
+		return nil, err
+	}
+
+	summaries := []Summary{}
+	for _, m := range machines {
+		info, err := m.VMInfo(ctx)
+		if err != nil {
+			return summaries, err
+		}
+		dir, ok := info.SharedFolders()["workbench"]
+		if !ok {
+			continue
+		}
+
+		s := Summary{Name: m.Name, Dir: dir, State: info["VMState"]}
+		if s.State == "running" {
+			if ip, err := m.IP(ctx); err == nil {
+				s.IP = ip
+			}
+		}
+		summaries = append(summaries, s.withApps())
+	}
+
+	records, err := LoadRecords()
+	if err != nil {
+		return summaries, err
+	}
+	for _, record := range records {
+		driver, err := machine.NewDriver(record.Driver)
+		if err != nil || driver.Name() == "virtualbox" {
+			continue
+		}
+		m := &machine.Machine{Name: record.Name, Driver: driver, Runner: r}
+		state, err := m.State(ctx)
+		if errors.Is(err, machine.ErrMachineNotFound) {
+			continue
+		}
+		s := Summary{Name: m.Name, Dir: record.Folder, State: strings.ToLower(state)}
+		if state == "Running" {
+			if ip, err := m.IP(ctx); err == nil {
+				s.IP = ip
+			}
+		}
+		summaries = append(summaries, s.withApps())
+	}
+	return summaries, ctx.Err()
+}
+
+// withApps adds the apps in the workbench directory to the summary
+func (s Summary) withApps() Summary {
+	apps, _ := FindApps(s.Dir)
+	for _, app := range apps {
+		a := App{Name: app}
+		if s.IP != "" {
+			a.URL = fmt.Sprintf("http://%s.%s.nip.io/", app, s.IP)
+		}
+		s.Apps = append(s.Apps, a)
+	}
+	return s
+}
+
+// FindApps returns the names of the subdirectories of dir that contain a docker-compose file
+func FindApps(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	apps := []string{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		for _, f := range ComposeFiles {
+			if _, err := os.Stat(filepath.Join(dir, e.Name(), f)); err == nil {
+				apps = append(apps, e.Name())
+				break
+			}
+		}
+	}
+	sort.Strings(apps)
+	return apps, nil
+}
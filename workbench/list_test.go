@@ -0,0 +1,55 @@
+package workbench
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/justincarter/docker-workbench/run"
+)
+
+func makeWorkbenchDir(t *testing.T) string {
+	dir := t.TempDir()
+	for _, f := range []string{"myapp/docker-compose.yml", "other/compose.yaml", "notes/README.md"} {
+		path := filepath.Join(dir, f)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte{}, 0644)
+	}
+	return dir
+}
+
+func TestFindApps(t *testing.T) {
+	apps, err := FindApps(makeWorkbenchDir(t))
+	if err != nil || !reflect.DeepEqual([]string{"myapp", "other"}, apps) {
+		t.Errorf("unexpected apps: %v %v", apps, err)
+	}
+}
+
+func TestList(t *testing.T) {
+	dir := makeWorkbenchDir(t)
+	r := run.NewFakeRunner().
+		On(run.VBoxManagePath()+" list vms", run.Response{Stdout: "\"default\" {1f6c4f0e}\n\"workbench\" {9b2d1a7c}\n"}).
+		On(run.VBoxManagePath()+" showvminfo workbench", run.Response{Stdout: "VMState=\"running\"\nSharedFolderNameMachineMapping1=\"workbench\"\nSharedFolderPathMachineMapping1=\"" + dir + "\"\n"}).
+		On(run.VBoxManagePath()+" showvminfo default", run.Response{Stdout: "VMState=\"poweroff\"\n"}).
+		On("docker-machine ip workbench", run.Response{Stdout: "192.168.99.100\n"})
+
+	summaries, err := List(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Summary{{
+		Name:  "workbench",
+		Dir:   dir,
+		State: "running",
+		IP:    "192.168.99.100",
+		Apps: []App{
+			{Name: "myapp", URL: "http://myapp.192.168.99.100.nip.io/"},
+			{Name: "other", URL: "http://other.192.168.99.100.nip.io/"},
+		},
+	}}
+	if !reflect.DeepEqual(expected, summaries) {
+		t.Errorf("unexpected summaries: %+v", summaries)
+	}
+}
@@ -0,0 +1,64 @@
+package workbench
+
+import (
+	"context"
+	"fmt"
+)
+
+// ProvisionResult reports whether a step of creating a workbench machine had drifted
+type ProvisionResult struct {
+	Step string
+	// Drift describes what had changed, or is empty if the step was up to date
+	Drift string
+}
+
+// Provision inspects the existing workbench machine and re-applies each step of creating it that
+// has drifted, leaving the machine running. Checks that need SSH are run with the machine started,
+// and the machine is stopped first for fixes that need it stopped. Fixes that only take effect at
+// boot, such as a rewritten bootsync.sh, are applied by restarting the machine at the end.
+func (w *Workbench) Provision(ctx context.Context) ([]ProvisionResult, error) {
+	m := &w.Machine
+	results := []ProvisionResult{}
+	restart := false
+	for _, step := range w.CreateSteps() {
+		if step.Check == nil {
+			continue
+		}
+		if step.Needs == "Running" {
+			if err := ensureState(ctx, m, step.Needs); err != nil {
+				return results, err
+			}
+		}
+		drift, err := step.Check(ctx)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, ProvisionResult{Step: step.Name, Drift: drift})
+		if drift == "" {
+			continue
+		}
+
+		fix := step.Fix
+		if fix == nil {
+			fix = step.Do
+		}
+		if err := ensureState(ctx, m, step.Needs); err != nil {
+			return results, err
+		}
+		if step.Needs == "Stopped" {
+			// the machine boots again at the end
+			restart = false
+		}
+		if err := fix(ctx); err != nil {
+			return results, err
+		}
+		restart = restart || step.Restart
+	}
+	if restart {
+		fmt.Printf("Restarting \"%s\" to apply the changes...\n", m.Name)
+		if err := ensureState(ctx, m, "Stopped"); err != nil {
+			return results, err
+		}
+	}
+	return results, ensureState(ctx, m, "Running")
+}
@@ -0,0 +1,66 @@
+package workbench
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/justincarter/docker-workbench/run"
+)
+
+func TestProvision(t *testing.T) {
+	r := run.NewFakeRunner().
+		On("docker-machine status", run.Response{Stdout: "Running\n"}).
+		On("docker-machine ssh workbench cat", run.Response{Stdout: "sudo mkdir -p /workbench && sudo mount -t vboxsf -o uid=1000,gid=50 workbench /workbench\n"}).
+		On("docker-machine ssh workbench docker inspect", run.Response{Stderr: "No such object", ExitCode: 1}).
+		On(run.VBoxManagePath()+" showvminfo", run.Response{Stdout: "SharedFolderNameMachineMapping1=\"workbench\"\nSharedFolderPathMachineMapping1=\"/old/workbench\"\n"})
+	w := newTestWorkbench(r)
+
+	results, err := w.Provision(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ProvisionResult{
+		{Step: "bootsync"},
+		{Step: "proxy", Drift: "docker_workbench_proxy container is missing"},
+		{Step: "sharedfolder", Drift: "workbench shared folder points to /old/workbench"},
+	}
+	if !reflect.DeepEqual(expected, results) {
+		t.Errorf("unexpected results: %+v", results)
+	}
+
+	fixes := []string{}
+	for _, c := range r.Calls() {
+		switch c {
+		case "docker-machine ssh workbench docker rm -f docker_workbench_proxy 2> /dev/null || true",
+			"docker-machine stop workbench",
+			run.VBoxManagePath() + " sharedfolder remove workbench --name workbench",
+			run.VBoxManagePath() + " sharedfolder add workbench --name workbench --hostpath /d/workbench":
+			fixes = append(fixes, c)
+		}
+	}
+	if len(fixes) != 4 {
+		t.Errorf("unexpected calls: %v", r.Calls())
+	}
+}
+
+func TestProvision_BootScriptRestarts(t *testing.T) {
+	r := run.NewFakeRunner().
+		On("docker-machine status", run.Response{Stdout: "Running\n"}).
+		On("docker-machine ssh workbench cat", run.Response{Stdout: "sudo mkdir -p /workbench\n"}).
+		On("docker-machine ssh workbench docker inspect", run.Response{Stdout: "true " + ProxyImage + "\n"}).
+		On(run.VBoxManagePath()+" showvminfo", run.Response{Stdout: "SharedFolderNameMachineMapping1=\"workbench\"\nSharedFolderPathMachineMapping1=\"/d/workbench\"\n"})
+	w := newTestWorkbench(r)
+
+	results, err := w.Provision(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Drift != "bootsync.sh has been changed" || results[1].Drift != "" || results[2].Drift != "" {
+		t.Errorf("unexpected results: %+v", results)
+	}
+	calls := r.Calls()
+	if calls[len(calls)-2] != "docker-machine stop workbench" {
+		t.Errorf("the machine was not restarted to run bootsync.sh: %v", calls)
+	}
+}
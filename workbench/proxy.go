@@ -0,0 +1,184 @@
+package workbench
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"time"
+)
+
+// ProxyTargetPort is the port of the proxy container on the machine that the reverse proxy forwards to
+var ProxyTargetPort = "80"
+
+// ProxyFlushInterval is how often the reverse proxy flushes response bodies to the client. It is
+// negative to flush after every write, so that server-sent events and other streamed responses
+// arrive as soon as the app sends them rather than when a buffer fills.
+var ProxyFlushInterval time.Duration = -1
+
+// ProxyOptions configures the reverse proxy started by StartProxy
+type ProxyOptions struct {
+	// Port is the port to serve HTTP on
+	Port string
+	// CA issues the certificates for serving HTTPS on TLSPort, if it is set
+	CA      *CA
+	TLSPort string
+	// Rewrite replaces the internal host name of the app, e.g. myapp.192.168.99.100.nip.io, with
+	// the host the client used in Location headers, Set-Cookie domains and HTML and JSON bodies
+	Rewrite bool
+	// Inspector captures the requests through the proxy, serving its web page on InspectAddr,
+	// if it is set
+	Inspector   *Inspector
+	InspectAddr string
+}
+
+// StartProxy will start a reverse proxy to the machine at the given IP address for the workbench,
+// running until ctx is done. A workbench for the "*" app routes each request to the app named by
+// the first label of its Host, e.g. myapp.192.168.0.10.nip.io, so that one proxy serves every
+// app in the workbench, including apps added while it is running.
+func (w *Workbench) StartProxy(ctx context.Context, ip string, opts ProxyOptions) error {
+	handler := w.proxyHandler(ip, opts)
+	if opts.Inspector != nil {
+		handler = opts.Inspector.Middleware(handler)
+	}
+	l, err := net.Listen("tcp4", fmt.Sprintf(":%s", opts.Port))
+	if err != nil {
+		return err
+	}
+	servers := []*http.Server{{Handler: handler}}
+	listeners := []net.Listener{l}
+	closeListeners := func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}
+	if opts.CA != nil {
+		tl, err := net.Listen("tcp4", fmt.Sprintf(":%s", opts.TLSPort))
+		if err != nil {
+			closeListeners()
+			return err
+		}
+		servers = append(servers, &http.Server{Handler: handler})
+		listeners = append(listeners, tls.NewListener(tl, opts.CA.TLSConfig(w.hasApp)))
+	}
+	if opts.Inspector != nil {
+		il, err := net.Listen("tcp4", opts.InspectAddr)
+		if err != nil {
+			closeListeners()
+			return err
+		}
+		servers = append(servers, &http.Server{Handler: opts.Inspector.Handler()})
+		listeners = append(listeners, il)
+	}
+
+	closeAll := func() {
+		for _, srv := range servers {
+			srv.Close()
+		}
+	}
+	go func() {
+		<-ctx.Done()
+		closeAll()
+	}()
+	errs := make(chan error, len(servers))
+	for i := range servers {
+		go func(srv *http.Server, l net.Listener) {
+			errs <- srv.Serve(l)
+		}(servers[i], listeners[i])
+	}
+	// stop every server as soon as one fails
+	var result error
+	for range servers {
+		if err := <-errs; err != nil && err != http.ErrServerClosed && result == nil {
+			result = err
+			closeAll()
+		}
+	}
+	return result
+}
+
+// proxyHandler returns the reverse proxy to the machine at ip. The Host header the client sent is
+// passed on unchanged, along with X-Forwarded headers, so that the app can build URLs that work
+// on the client. WebSocket and other upgraded connections are passed through by
+// httputil.ReverseProxy once the app switches protocols, and the servers have no timeouts so
+// that long-lived connections are not cut off.
+func (w *Workbench) proxyHandler(ip string, opts ProxyOptions) http.Handler {
+	proxy := &httputil.ReverseProxy{
+		Director: func(r *http.Request) {
+			app := w.App
+			if app == "*" {
+				app = HostApp(r.Host)
+			}
+			r.URL.Scheme = "http"
+			r.URL.Host = fmt.Sprintf("%s.%s.nip.io", app, ip)
+			if _, ok := r.Header["User-Agent"]; !ok {
+				// stop net/http adding its own User-Agent
+				r.Header.Set("User-Agent", "")
+			}
+			setForwardedHeaders(r)
+			if opts.Rewrite {
+				// let the transport ask for and decompress gzip, so that bodies can be rewritten
+				r.Header.Del("Accept-Encoding")
+			}
+		},
+		Transport:     proxyTransport(ip),
+		FlushInterval: ProxyFlushInterval,
+	}
+	if opts.Rewrite {
+		proxy.ModifyResponse = rewriteResponse
+	}
+	if w.App != "*" {
+		return proxy
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		app := HostApp(r.Host)
+		if w.hasApp(app) {
+			proxy.ServeHTTP(rw, r)
+			return
+		}
+		apps, _ := FindApps(w.Dir)
+		http.Error(rw, fmt.Sprintf("There is no app called '%s' in workbench '%s'. The apps are: %s",
+			app, w.Name, strings.Join(apps, ", ")), http.StatusNotFound)
+	})
+}
+
+// hasApp reports whether the proxy serves app, which for the "*" app is any app in the workbench
+func (w *Workbench) hasApp(app string) bool {
+	if w.App != "*" {
+		return strings.EqualFold(app, w.App)
+	}
+	apps, _ := FindApps(w.Dir)
+	for _, a := range apps {
+		if strings.EqualFold(a, app) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyTransport connects to the machine at ip directly rather than looking up the nip.io
+// hostname, which some routers refuse to resolve to a private address. It only speaks HTTP/1.1,
+// which is what the proxy container on the machine upgrades WebSocket connections from.
+func proxyTransport(ip string) *http.Transport {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.ForceAttemptHTTP2 = false
+	t.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, net.JoinHostPort(ip, ProxyTargetPort))
+	}
+	return t
+}
+
+// HostApp returns the app named by the first label of a Host header, e.g. myapp for
+// myapp.192.168.0.10.nip.io:8080
+func HostApp(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.SplitN(host, ".", 2)[0])
+}
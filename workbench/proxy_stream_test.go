@@ -0,0 +1,217 @@
+package workbench
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// websocketGUID is the key suffix from RFC 6455 used to compute Sec-WebSocket-Accept
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+func websocketAccept(key string) string {
+	h := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// writeFrame writes a short final text frame, masked as a client must
+func writeFrame(w io.Writer, payload string, masked bool) error {
+	header := []byte{0x81, byte(len(payload))}
+	data := []byte(payload)
+	if masked {
+		mask := []byte{1, 2, 3, 4}
+		header[1] |= 0x80
+		header = append(header, mask...)
+		for i := range data {
+			data[i] ^= mask[i%4]
+		}
+	}
+	_, err := w.Write(append(header, data...))
+	return err
+}
+
+// readFrame reads a short text frame, unmasking it if needed
+func readFrame(r io.Reader) (string, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", err
+	}
+	mask := []byte{0, 0, 0, 0}
+	if header[1]&0x80 != 0 {
+		if _, err := io.ReadFull(r, mask); err != nil {
+			return "", err
+		}
+	}
+	data := make([]byte, header[1]&0x7f)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return "", err
+	}
+	for i := range data {
+		data[i] ^= mask[i%4]
+	}
+	return string(data), nil
+}
+
+// websocketEcho is a WebSocket server that echoes text frames back prefixed with "echo: "
+func websocketEcho(rw http.ResponseWriter, r *http.Request) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		http.Error(rw, "expected a WebSocket upgrade", http.StatusBadRequest)
+		return
+	}
+	conn, buf, err := rw.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	fmt.Fprintf(buf, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		websocketAccept(r.Header.Get("Sec-WebSocket-Key")))
+	buf.Flush()
+	for {
+		msg, err := readFrame(buf)
+		if err != nil {
+			return
+		}
+		writeFrame(conn, "echo: "+msg, false)
+	}
+}
+
+func TestProxy_WebSocket(t *testing.T) {
+	useTestBackend(t, websocketEcho)
+	w := newTestWorkbench(nil)
+	w.App = "myapp"
+	proxy := httptest.NewServer(w.proxyHandler("127.0.0.1", ProxyOptions{}))
+	defer proxy.Close()
+
+	conn, err := net.Dial("tcp", proxy.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	fmt.Fprintf(conn, "GET /live HTTP/1.1\r\nHost: myapp.192.168.0.10.nip.io:8080\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n", key)
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != websocketAccept(key) {
+		t.Fatalf("upgrade failed: %s %v", resp.Status, resp.Header)
+	}
+
+	for _, msg := range []string{"hello", "reload"} {
+		if err := writeFrame(conn, msg, true); err != nil {
+			t.Fatal(err)
+		}
+		echo, err := readFrame(r)
+		if err != nil || echo != "echo: "+msg {
+			t.Errorf("unexpected echo: %q %v", echo, err)
+		}
+	}
+}
+
+func TestProxy_ServerSentEvents(t *testing.T) {
+	// the backend only sends each event once the client has received the one before, so the test
+	// would time out if the proxy buffered the stream
+	received := make(chan struct{})
+	useTestBackend(t, func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/event-stream")
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(rw, "data: %d\n\n", i)
+			rw.(http.Flusher).Flush()
+			select {
+			case <-received:
+			case <-time.After(5 * time.Second):
+				return
+			}
+		}
+	})
+	w := newTestWorkbench(nil)
+	w.App = "myapp"
+	proxy := httptest.NewServer(w.proxyHandler("127.0.0.1", ProxyOptions{}))
+	defer proxy.Close()
+
+	req, _ := http.NewRequest("GET", proxy.URL+"/events", nil)
+	req.Host = "myapp.192.168.0.10.nip.io:8080"
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	events := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if line := scanner.Text(); line != "" {
+				events <- line
+			}
+		}
+		close(events)
+	}()
+	for i := 1; i <= 3; i++ {
+		select {
+		case event := <-events:
+			if event != fmt.Sprintf("data: %d", i) {
+				t.Fatalf("unexpected event: %s", event)
+			}
+			received <- struct{}{}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("event %d was not streamed", i)
+		}
+	}
+}
+
+func TestProxy_StreamedBody(t *testing.T) {
+	// a chunked response without an event stream content type is flushed as it is written too
+	received := make(chan struct{})
+	useTestBackend(t, func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/x-ndjson")
+		io.WriteString(rw, "{\"progress\":50}\n")
+		rw.(http.Flusher).Flush()
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+		}
+		io.WriteString(rw, "{\"progress\":100}\n")
+	})
+	w := newTestWorkbench(nil)
+	w.App = "myapp"
+	proxy := httptest.NewServer(w.proxyHandler("127.0.0.1", ProxyOptions{}))
+	defer proxy.Close()
+
+	resp, err := http.Get(proxy.URL + "/build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	r := bufio.NewReader(resp.Body)
+
+	first := make(chan string)
+	go func() {
+		line, _ := r.ReadString('\n')
+		first <- line
+	}()
+	select {
+	case line := <-first:
+		if line != "{\"progress\":50}\n" {
+			t.Errorf("unexpected line: %s", line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the first line was not streamed")
+	}
+	close(received)
+	if rest, _ := io.ReadAll(r); string(rest) != "{\"progress\":100}\n" {
+		t.Errorf("unexpected rest of body: %s", rest)
+	}
+}
@@ -0,0 +1,179 @@
+package workbench
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useTestBackend starts an HTTP server standing in for the proxy container on the machine, which
+// answers with the Host header it received
+func useTestBackend(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	if handler == nil {
+		handler = func(rw http.ResponseWriter, r *http.Request) { io.WriteString(rw, r.Host) }
+	}
+	backend := httptest.NewServer(handler)
+	t.Cleanup(backend.Close)
+	_, port, _ := net.SplitHostPort(backend.Listener.Addr().String())
+	original := ProxyTargetPort
+	ProxyTargetPort = port
+	t.Cleanup(func() { ProxyTargetPort = original })
+}
+
+// proxyGet requests path from the proxy with the given Host header
+func proxyGet(t *testing.T, proxy *httptest.Server, host, path string) (int, string) {
+	t.Helper()
+	req, _ := http.NewRequest("GET", proxy.URL+path, nil)
+	req.Host = host
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestProxy_App(t *testing.T) {
+	useTestBackend(t, nil)
+	w := newTestWorkbench(nil)
+	w.App = "myapp"
+	proxy := httptest.NewServer(w.proxyHandler("127.0.0.1", ProxyOptions{}))
+	defer proxy.Close()
+
+	code, body := proxyGet(t, proxy, "myapp.192.168.0.10.nip.io:8080", "/")
+	if code != 200 || body != "myapp.192.168.0.10.nip.io:8080" {
+		t.Errorf("unexpected response: %d %s", code, body)
+	}
+}
+
+func TestProxy_AllApps(t *testing.T) {
+	useTestBackend(t, nil)
+	w := newTestWorkbench(nil)
+	w.Dir = makeWorkbenchDir(t)
+	proxy := httptest.NewServer(w.proxyHandler("127.0.0.1", ProxyOptions{}))
+	defer proxy.Close()
+
+	if code, body := proxyGet(t, proxy, "Other.192.168.0.10.nip.io:8080", "/"); code != 200 || body != "Other.192.168.0.10.nip.io:8080" {
+		t.Errorf("unexpected response: %d %s", code, body)
+	}
+	if code, body := proxyGet(t, proxy, "newapp.192.168.0.10.nip.io:8080", "/"); code != 404 || !strings.Contains(body, "myapp, other") {
+		t.Errorf("unexpected response for a missing app: %d %s", code, body)
+	}
+
+	os.Mkdir(filepath.Join(w.Dir, "newapp"), 0755)
+	os.WriteFile(filepath.Join(w.Dir, "newapp", "docker-compose.yml"), []byte{}, 0644)
+	if code, _ := proxyGet(t, proxy, "newapp.192.168.0.10.nip.io:8080", "/"); code != 200 {
+		t.Errorf("new app was not proxied: %d", code)
+	}
+}
+
+func TestHostApp(t *testing.T) {
+	hosts := map[string]string{
+		"myapp.192.168.0.10.nip.io:8080": "myapp",
+		"MyApp.192.168.0.10.nip.io":      "myapp",
+		"192.168.0.10:8080":              "192",
+		"localhost":                      "localhost",
+	}
+	for host, app := range hosts {
+		if HostApp(host) != app {
+			t.Errorf("unexpected app for %s: %s", host, HostApp(host))
+		}
+	}
+}
+
+func TestProxy_ForwardedHeaders(t *testing.T) {
+	useTestBackend(t, func(rw http.ResponseWriter, r *http.Request) {
+		for _, k := range []string{"X-Forwarded-Host", "X-Forwarded-Proto", "X-Forwarded-Port"} {
+			io.WriteString(rw, r.Header.Get(k)+"\n")
+		}
+		if r.Header.Get("X-Forwarded-For") == "" {
+			io.WriteString(rw, "missing X-Forwarded-For")
+		}
+	})
+	w := newTestWorkbench(nil)
+	w.App = "myapp"
+	proxy := httptest.NewServer(w.proxyHandler("127.0.0.1", ProxyOptions{}))
+	defer proxy.Close()
+
+	_, body := proxyGet(t, proxy, "myapp.192.168.0.10.nip.io:8080", "/")
+	if body != "myapp.192.168.0.10.nip.io:8080\nhttp\n8080\n" {
+		t.Errorf("unexpected forwarded headers:\n%s", body)
+	}
+	_, body = proxyGet(t, proxy, "myapp.192.168.0.10.nip.io", "/")
+	if body != "myapp.192.168.0.10.nip.io\nhttp\n80\n" {
+		t.Errorf("unexpected forwarded headers without a port:\n%s", body)
+	}
+}
+
+func TestProxy_Rewrite(t *testing.T) {
+	useTestBackend(t, func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			rw.Header().Add("Set-Cookie", "session=1; Domain=myapp.127.0.0.1.nip.io; Path=/")
+			http.Redirect(rw, r, "http://myapp.127.0.0.1.nip.io/home", http.StatusFound)
+		case "/page":
+			rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+			io.WriteString(rw, `<a href="http://myapp.127.0.0.1.nip.io/a">a</a><script src="//myapp.127.0.0.1.nip.io/b.js"></script>`)
+		case "/api":
+			rw.Header().Set("Content-Type", "application/json")
+			io.WriteString(rw, `{"next":"http:\/\/myapp.127.0.0.1.nip.io\/api?page=2"}`)
+		default:
+			rw.Header().Set("Content-Type", "text/plain")
+			io.WriteString(rw, "http://myapp.127.0.0.1.nip.io/")
+		}
+	})
+	w := newTestWorkbench(nil)
+	w.App = "myapp"
+	proxy := httptest.NewServer(w.proxyHandler("127.0.0.1", ProxyOptions{Rewrite: true}))
+	defer proxy.Close()
+	host := "myapp.192.168.0.10.nip.io:8080"
+
+	req, _ := http.NewRequest("GET", proxy.URL+"/login", nil)
+	req.Host = host
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if loc := resp.Header.Get("Location"); loc != "http://myapp.192.168.0.10.nip.io:8080/home" {
+		t.Errorf("Location was not rewritten: %s", loc)
+	}
+	if cookie := resp.Header.Get("Set-Cookie"); cookie != "session=1; Domain=myapp.192.168.0.10.nip.io; Path=/" {
+		t.Errorf("Set-Cookie was not rewritten: %s", cookie)
+	}
+
+	bodies := map[string]string{
+		"/page":  `<a href="http://myapp.192.168.0.10.nip.io:8080/a">a</a><script src="//myapp.192.168.0.10.nip.io:8080/b.js"></script>`,
+		"/api":   `{"next":"http:\/\/myapp.192.168.0.10.nip.io:8080\/api?page=2"}`,
+		"/plain": "http://myapp.127.0.0.1.nip.io/",
+	}
+	for path, expected := range bodies {
+		if _, body := proxyGet(t, proxy, host, path); body != expected {
+			t.Errorf("unexpected body for %s: %s", path, body)
+		}
+	}
+}
+
+func TestRewriteCookie(t *testing.T) {
+	internal := "myapp.192.168.99.100.nip.io"
+	cookies := []struct{ cookie, external, expected string }{
+		{"a=1; Domain=myapp.192.168.99.100.nip.io", "myapp.192.168.0.10.nip.io", "a=1; Domain=myapp.192.168.0.10.nip.io"},
+		{"a=1; domain=.192.168.99.100.nip.io; Path=/", "myapp.192.168.0.10.nip.io", "a=1; Domain=192.168.0.10.nip.io; Path=/"},
+		{"a=1; Domain=myapp.192.168.99.100.nip.io; Path=/", "192.168.0.10", "a=1; Path=/"},
+		{"a=1; Domain=192.168.99.100.nip.io", "localhost", "a=1"},
+		{"a=1; Domain=example.com", "myapp.192.168.0.10.nip.io", "a=1; Domain=example.com"},
+		{"a=1; Path=/", "myapp.192.168.0.10.nip.io", "a=1; Path=/"},
+	}
+	for _, c := range cookies {
+		if actual := rewriteCookie(c.cookie, internal, c.external); actual != c.expected {
+			t.Errorf("unexpected cookie for %q on %s: %q", c.cookie, c.external, actual)
+		}
+	}
+}
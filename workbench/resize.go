@@ -0,0 +1,114 @@
+package workbench
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/justincarter/docker-workbench/machine"
+)
+
+// Resize changes the CPUs, memory (MB) and disk size (MB) of a VirtualBox workbench machine,
+// leaving any that are 0 unchanged. The machine is stopped to apply the changes and started again
+// afterwards. Disks can only grow, as VirtualBox cannot shrink a disk image.
+func (w *Workbench) Resize(ctx context.Context, cpus, memory, disk int) error {
+	m := &w.Machine
+	driver, err := m.DriverName(ctx)
+	if err != nil {
+		return err
+	}
+	if driver != "virtualbox" {
+		return fmt.Errorf("docker-workbench: '%s' uses the %s driver, but only VirtualBox workbenches can be resized", m.Name, driver)
+	}
+
+	info, err := m.VMInfo(ctx)
+	if err != nil {
+		return err
+	}
+	current := struct{ cpus, memory, disk int }{}
+	current.cpus, _ = strconv.Atoi(info["cpus"])
+	current.memory, _ = strconv.Atoi(info["memory"])
+	path := info.Disk()
+	if path == "" {
+		return fmt.Errorf("docker-workbench: could not find the disk image of '%s'", m.Name)
+	}
+	if current.disk, err = m.DiskSize(ctx, path); err != nil {
+		return err
+	}
+
+	if cpus == current.cpus {
+		cpus = 0
+	}
+	if memory == current.memory {
+		memory = 0
+	}
+	switch {
+	case disk == current.disk:
+		disk = 0
+	case disk > 0 && disk < current.disk:
+		return fmt.Errorf("docker-workbench: the disk is already %d MB and cannot be shrunk to %d MB", current.disk, disk)
+	}
+	if cpus == 0 && memory == 0 && disk == 0 {
+		fmt.Printf("\"%s\" already has those resources.\n", m.Name)
+		return nil
+	}
+	if err := machine.ValidateResources(valueOr(cpus, current.cpus), valueOr(memory, current.memory), valueOr(disk, current.disk)); err != nil {
+		return err
+	}
+
+	state, err := m.State(ctx)
+	if err != nil {
+		return err
+	}
+	if state != "Stopped" {
+		if err := m.Stop(ctx); err != nil {
+			return err
+		}
+	}
+	if cpus > 0 || memory > 0 {
+		fmt.Printf("Changing \"%s\" to %d CPUs and %d MB of memory...\n", m.Name, valueOr(cpus, current.cpus), valueOr(memory, current.memory))
+		if err := m.ModifyVM(ctx, cpus, memory); err != nil {
+			return err
+		}
+	}
+	if disk > 0 {
+		fmt.Printf("Growing the disk of \"%s\" to %d MB...\n", m.Name, disk)
+		if err := m.ResizeDisk(ctx, path, disk); err != nil {
+			return err
+		}
+	}
+	if err := m.Start(ctx); err != nil {
+		return err
+	}
+
+	return w.recordResources(cpus, memory, disk)
+}
+
+// recordResources updates the saved record with the new resources so that recreate keeps them
+func (w *Workbench) recordResources(cpus, memory, disk int) error {
+	record, err := LoadRecord(w.Name)
+	if err != nil || record == nil {
+		return err
+	}
+	if record.Env == nil {
+		record.Env = map[string]string{}
+	}
+	for key, value := range map[string]int{
+		"VIRTUALBOX_CPU_COUNT":   cpus,
+		"VIRTUALBOX_MEMORY_SIZE": memory,
+		"VIRTUALBOX_DISK_SIZE":   disk,
+	} {
+		if value > 0 {
+			record.Env[key] = strconv.Itoa(value)
+		}
+	}
+	return record.Save()
+}
+
+// valueOr returns value, or fallback if value is 0
+func valueOr(value, fallback int) int {
+	if value == 0 {
+		return fallback
+	}
+	return value
+}
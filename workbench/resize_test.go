@@ -0,0 +1,57 @@
+package workbench
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/justincarter/docker-workbench/run"
+)
+
+func TestResize(t *testing.T) {
+	useTempConfigDir(t)
+	useTestHost(t)
+	(&Record{Name: "workbench", Env: map[string]string{"VIRTUALBOX_MEMORY_SIZE": "2048"}}).Save()
+
+	r := run.NewFakeRunner().
+		On("docker-machine inspect", run.Response{Stdout: "virtualbox\n"}).
+		On(run.VBoxManagePath()+" showvminfo", run.Response{Stdout: "cpus=2\nmemory=2048\n\"SATA-0-0\"=\"/vms/workbench/disk.vmdk\"\n"}).
+		On(run.VBoxManagePath()+" showmediuminfo", run.Response{Stdout: "UUID:           1234\nCapacity:       60000 MBytes\n"}).
+		On("docker-machine status", run.Response{Stdout: "Running\n"})
+	w := newTestWorkbench(r)
+	if err := w.Resize(context.Background(), 2, 4096, 80000); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"docker-machine inspect --format {{.DriverName}} workbench",
+		run.VBoxManagePath() + " showvminfo workbench --machinereadable",
+		run.VBoxManagePath() + " showmediuminfo disk /vms/workbench/disk.vmdk",
+		"docker-machine status workbench",
+		"docker-machine stop workbench",
+		run.VBoxManagePath() + " modifyvm workbench --memory 4096",
+		run.VBoxManagePath() + " modifymedium disk /vms/workbench/disk.vmdk --resize 80000",
+		"docker-machine start workbench",
+	}
+	if !reflect.DeepEqual(expected, r.Calls()) {
+		t.Errorf("unexpected calls: %v", r.Calls())
+	}
+	if record, _ := LoadRecord("workbench"); record.Env["VIRTUALBOX_MEMORY_SIZE"] != "4096" || record.Env["VIRTUALBOX_DISK_SIZE"] != "80000" {
+		t.Errorf("record was not updated: %+v", record)
+	}
+
+	// disks cannot shrink
+	if err := w.Resize(context.Background(), 0, 0, 20000); err == nil {
+		t.Error("expected shrinking the disk to fail")
+	}
+}
+
+func TestResize_NotVirtualBox(t *testing.T) {
+	r := run.NewFakeRunner().On("docker-machine inspect", run.Response{Stdout: "generic\n"})
+	w := newTestWorkbench(r)
+	if err := w.Resize(context.Background(), 4, 0, 0); err == nil {
+		t.Fatal("expected resize to be refused")
+	}
+	if len(r.Calls()) != 1 {
+		t.Errorf("unexpected calls: %v", r.Calls())
+	}
+}
@@ -0,0 +1,120 @@
+package workbench
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// setForwardedHeaders tells the app which host, scheme and port the client used, since the Host
+// header alone does not say whether the client connected over HTTPS. X-Forwarded-For is added by
+// httputil.ReverseProxy itself.
+func setForwardedHeaders(r *http.Request) {
+	proto, port := "http", "80"
+	if r.TLS != nil {
+		proto, port = "https", "443"
+	}
+	if _, p, err := net.SplitHostPort(r.Host); err == nil {
+		port = p
+	}
+	r.Header.Set("X-Forwarded-Host", r.Host)
+	r.Header.Set("X-Forwarded-Proto", proto)
+	r.Header.Set("X-Forwarded-Port", port)
+}
+
+// rewriteResponse replaces the internal host name of the app on the machine with the host the
+// client used, for apps that build absolute URLs from their own configuration rather than from
+// the request. It relies on the headers set by setForwardedHeaders.
+func rewriteResponse(resp *http.Response) error {
+	internal := resp.Request.URL.Hostname()
+	external := resp.Request.Header.Get("X-Forwarded-Host")
+	if external == "" {
+		return nil
+	}
+	base := resp.Request.Header.Get("X-Forwarded-Proto") + "://" + external
+
+	urls := strings.NewReplacer(
+		"http://"+internal, base,
+		"https://"+internal, base,
+		"//"+internal, "//"+external,
+		// as escaped by JSON encoders
+		`http:\/\/`+internal, strings.ReplaceAll(base, "/", `\/`),
+		`https:\/\/`+internal, strings.ReplaceAll(base, "/", `\/`),
+		`\/\/`+internal, `\/\/`+external,
+	)
+	if loc := resp.Header.Get("Location"); loc != "" {
+		resp.Header.Set("Location", urls.Replace(loc))
+	}
+	if cookies := resp.Header.Values("Set-Cookie"); len(cookies) > 0 {
+		hostname := external
+		if h, _, err := net.SplitHostPort(external); err == nil {
+			hostname = h
+		}
+		resp.Header.Del("Set-Cookie")
+		for _, c := range cookies {
+			resp.Header.Add("Set-Cookie", rewriteCookie(c, internal, hostname))
+		}
+	}
+	return rewriteBody(resp, urls)
+}
+
+// rewriteCookie moves the Domain of a Set-Cookie header from the internal host name to the
+// external one. A cookie for a parent domain, e.g. 192.168.99.100.nip.io, is moved to the same
+// parent of the external host name, and loses its Domain when the external host has no such
+// parent so that it still applies to the host the client used.
+func rewriteCookie(cookie, internal, external string) string {
+	internal = strings.ToLower(internal)
+	attrs := strings.Split(cookie, ";")
+	for i := 1; i < len(attrs); i++ {
+		attr := strings.TrimSpace(attrs[i])
+		if len(attr) < 7 || !strings.EqualFold(attr[:7], "domain=") {
+			continue
+		}
+		domain := strings.ToLower(strings.TrimPrefix(attr[7:], "."))
+		var rewritten string
+		switch {
+		case domain == internal:
+			rewritten = external
+		case strings.HasSuffix(internal, "."+domain):
+			prefix := internal[:len(internal)-len(domain)]
+			if len(external) > len(prefix) && strings.EqualFold(external[:len(prefix)], prefix) {
+				rewritten = external[len(prefix):]
+			}
+		default:
+			continue
+		}
+		if rewritten == "" || net.ParseIP(rewritten) != nil {
+			attrs = append(attrs[:i], attrs[i+1:]...)
+			i--
+			continue
+		}
+		attrs[i] = " Domain=" + rewritten
+	}
+	return strings.Join(attrs, ";")
+}
+
+// rewriteBody replaces the absolute URLs in HTML and JSON responses. Requests are sent without
+// the client's Accept-Encoding when rewriting, so that the body arrives uncompressed.
+func rewriteBody(resp *http.Response, urls *strings.Replacer) error {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return nil
+	}
+	if resp.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	b = []byte(urls.Replace(string(b)))
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+	resp.ContentLength = int64(len(b))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(b)))
+	return nil
+}
@@ -0,0 +1,150 @@
+package workbench
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/justincarter/docker-workbench/machine"
+	"github.com/justincarter/docker-workbench/run"
+)
+
+// URLTimeout is how long the status check waits for the app URL to respond
+var URLTimeout = 5 * time.Second
+
+// Status reports the health of a workbench machine, its proxy and the current app
+type Status struct {
+	Machine string `json:"machine"`
+	App     string `json:"app,omitempty"`
+	State   string `json:"state"`
+	IP      string `json:"ip,omitempty"`
+	// DockerError is why docker-machine could not connect to the Docker engine (e.g. invalid TLS certificates)
+	DockerOK    bool   `json:"docker_ok"`
+	DockerError string `json:"docker_error,omitempty"`
+	// Proxy is the state of the proxy container: running, exited, missing or unknown
+	Proxy      string      `json:"proxy"`
+	Mounted    bool        `json:"workbench_mounted"`
+	Containers []Container `json:"containers,omitempty"`
+	URL        string      `json:"url,omitempty"`
+	// URLStatus is the HTTP status code returned by the app URL, or 0 if it did not respond
+	URLStatus int    `json:"url_status,omitempty"`
+	URLError  string `json:"url_error,omitempty"`
+}
+
+// Container is a docker-compose container belonging to an app
+type Container struct {
+	Name        string `json:"name"`
+	State       string `json:"state"`
+	VirtualHost string `json:"virtual_host,omitempty"`
+}
+
+// Status inspects the workbench without changing anything. Checks that fail are reported in the
+// Status rather than returned as errors, so only a missing machine or an interrupt returns an error.
+func (w *Workbench) Status(ctx context.Context) (*Status, error) {
+	s := &Status{Machine: w.Name, Proxy: "unknown"}
+	if w.App != "*" {
+		s.App = w.App
+	}
+
+	state, err := w.State(ctx)
+	if err != nil {
+		return s, err
+	}
+	s.State = state
+	if state != "Running" {
+		return s, ctx.Err()
+	}
+	if ip, err := w.IP(ctx); err == nil {
+		s.IP = ip
+	}
+
+	if _, err := w.EnvOutput(ctx); err != nil {
+		s.DockerError = err.Error()
+	} else {
+		s.DockerOK = true
+	}
+
+	out, err := w.SSHOutput(ctx, "docker inspect -f '{{.State.Status}}' "+ProxyContainer)
+	switch {
+	case err == nil:
+		s.Proxy = strings.TrimSpace(string(out))
+	case run.ExitCodeOf(err) > 0:
+		s.Proxy = "missing"
+	}
+
+	mounted := "grep -qs ' /workbench ' /proc/mounts"
+	if _, native := driverOf(&w.Machine).(machine.Native); native {
+		mounted = "[ -d /workbench ]"
+	}
+	_, err = w.SSHOutput(ctx, mounted)
+	s.Mounted = err == nil
+
+	if s.App != "" {
+		project := ComposeProject(s.App)
+		out, err = w.SSHOutput(ctx, "ids=$(docker ps -aq --filter label=com.docker.compose.project="+project+"); "+
+			"[ -z \"$ids\" ] || docker inspect -f '{{.Name}}|{{.State.Status}}|{{range .Config.Env}}{{.}};{{end}}' $ids")
+		if err == nil {
+			s.Containers = parseContainers(out)
+		}
+
+		if s.IP != "" {
+			host := fmt.Sprintf("%s.%s.nip.io", s.App, s.IP)
+			s.URL = fmt.Sprintf("http://%s/", host)
+			s.URLStatus, err = checkURL(ctx, "http://"+s.IP+"/", host)
+			if err != nil {
+				s.URLError = err.Error()
+			}
+		}
+	}
+
+	return s, ctx.Err()
+}
+
+// ComposeProject returns the docker-compose project name for an app directory
+func ComposeProject(app string) string {
+	return regexp.MustCompile(`[^a-z0-9_-]`).ReplaceAllString(strings.ToLower(app), "")
+}
+
+// parseContainers parses the name|state|env output of docker inspect into containers
+func parseContainers(output []byte) []Container {
+	containers := []Container{}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.SplitN(line, "|", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		c := Container{Name: strings.TrimPrefix(fields[0], "/"), State: fields[1]}
+		for _, env := range strings.Split(fields[2], ";") {
+			if strings.HasPrefix(env, "VIRTUAL_HOST=") {
+				c.VirtualHost = strings.TrimPrefix(env, "VIRTUAL_HOST=")
+			}
+		}
+		containers = append(containers, c)
+	}
+	return containers
+}
+
+// checkURL requests url with the given Host header, which is how the workbench proxy routes to an
+// app, and returns the response status code
+func checkURL(ctx context.Context, url, host string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, URLTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Host = host
+	client := &http.Client{
+		// report redirects rather than following them
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,63 @@
+package workbench
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/justincarter/docker-workbench/machine"
+	"github.com/justincarter/docker-workbench/run"
+)
+
+func TestParseContainers(t *testing.T) {
+	input := "/myapp_myapp_1|running|PATH=/usr/bin;VIRTUAL_HOST=myapp.*;\n/myapp_db_1|exited|MYSQL_ROOT_PASSWORD=secret;\n"
+	expected := []Container{
+		{Name: "myapp_myapp_1", State: "running", VirtualHost: "myapp.*"},
+		{Name: "myapp_db_1", State: "exited"},
+	}
+	if result := parseContainers([]byte(input)); !reflect.DeepEqual(expected, result) {
+		t.Errorf("unexpected containers: %+v", result)
+	}
+}
+
+func TestComposeProject(t *testing.T) {
+	if ComposeProject("My.App") != "myapp" || ComposeProject("my-app_2") != "my-app_2" {
+		t.Fail()
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "myapp.192.168.99.100.nip.io" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer srv.Close()
+
+	code, err := checkURL(context.Background(), srv.URL, "myapp.192.168.99.100.nip.io")
+	if err != nil || code != http.StatusTeapot {
+		t.Errorf("unexpected response: %d %v", code, err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	r := run.NewFakeRunner().
+		On("docker-machine status", run.Response{Stdout: "Running\n"}).
+		On("docker-machine ip", run.Response{Stdout: "192.168.99.100\n"}).
+		On("docker-machine env", run.Response{Stderr: "Error checking TLS connection: certificate has expired", ExitCode: 1}).
+		On("docker-machine ssh workbench docker inspect", run.Response{Stdout: "running\n"}).
+		On("docker-machine ssh workbench grep", run.Response{ExitCode: 1})
+	w := &Workbench{Machine: machine.Machine{Name: "workbench", Runner: r}, App: "*"}
+
+	s, err := w.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.State != "Running" || s.IP != "192.168.99.100" || s.DockerOK || s.DockerError == "" || s.Proxy != "running" || s.Mounted {
+		t.Errorf("unexpected status: %+v", s)
+	}
+}
@@ -1,76 +1,166 @@
 package workbench
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"net"
-	"net/http"
-	"net/http/httputil"
-	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/justincarter/docker-workbench/machine"
+	"github.com/justincarter/docker-workbench/run"
 )
 
 // Workbench represents a workbench and its app
 type Workbench struct {
 	machine.Machine
 	App string
+	// Dir is the workbench directory shared into the machine as /workbench
+	Dir string
+	// Config is loaded from the ConfigFile in Dir
+	Config *Config
+}
+
+// NewWorkbenchAt creates a workbench for a directory that may not have a machine yet, loading its
+// ConfigFile
+func NewWorkbenchAt(dir string) (*Workbench, error) {
+	w := &Workbench{App: "*", Dir: dir}
+	w.Name, w.Driver, _ = identify(dir)
+	return w, w.loadConfig()
 }
 
 // NewWorkbench creates a new workbench
-func NewWorkbench() (*Workbench, error) {
-	var err error
+func NewWorkbench(ctx context.Context) (*Workbench, error) {
 	// get name from the current working directory
 	workdir, _ := os.Getwd()
-	name := filepath.Base(workdir)
+	name, driver, ok := identify(workdir)
+	if !ok {
+		// check for a machine with the driver of the workbench this may be an app directory of
+		driver = DetectDriver(workdir)
+	}
 
 	// set up workbench
 	w := new(Workbench)
 	w.App = "*"
 	w.Name = name
+	w.Driver = driver
+	w.Dir = workdir
 
-	if !w.Exists() {
+	exists, err := w.Exists(ctx)
+	if err != nil {
+		return w, err
+	}
+	if !exists {
 		// get name from the parent of the current working directory
-		name := filepath.Base(filepath.Dir(workdir))
+		name, driver, _ := identify(filepath.Dir(workdir))
 
 		// set up workbench
-		w.App = w.Name
+		w.App = filepath.Base(workdir)
 		w.Name = name
+		w.Driver = driver
+		w.Dir = filepath.Dir(workdir)
 
-		if !w.Exists() {
-			err = fmt.Errorf("Workbench machine '%s' not found.", w.App)
+		exists, err = w.Exists(ctx)
+		if err != nil {
+			return w, err
+		}
+		if !exists {
+			return w, &machine.NotFoundError{Name: w.App}
 		}
 	}
 
-	return w, err
+	return w, w.loadConfig()
 }
 
-// PrintWorkbenchInfo prints the application URL using the app name and machine IP of the workbench
-func (w *Workbench) PrintWorkbenchInfo() {
-	ip, ok := w.IP()
-	if ok == true {
-		fmt.Println("\nBrowse the workbench using:")
-		fmt.Printf("http://%s.%s.nip.io/\n", w.App, ip)
-	} else {
-		fmt.Println("\nCould not find the IP address for this workbench")
-		os.Exit(1)
+// SetApp makes the workbench act on one of the app directories in Dir, as if it had been created
+// from that directory
+func (w *Workbench) SetApp(app string) error {
+	info, err := os.Stat(filepath.Join(w.Dir, app))
+	if app != filepath.Base(app) || app == "." || app == ".." || err != nil || !info.IsDir() {
+		return fmt.Errorf("docker-workbench: '%s' is not an app directory of workbench machine '%s'", app, w.Name)
+	}
+	w.App = app
+	return nil
+}
+
+// identify returns the name and driver of the machine for a workbench directory. Machines are
+// named after the directory and use the driver in its ConfigFile, unless they were created with
+// something else. ok is false if there is neither a record of the machine nor a ConfigFile driver.
+func identify(dir string) (name string, driver machine.Driver, ok bool) {
+	if record, _ := FindRecord(dir); record != nil {
+		driver, err := machine.NewDriver(record.Driver)
+		if err != nil {
+			driver = machine.VirtualBox{}
+		}
+		return record.Name, driver, true
+	}
+	driver = machine.VirtualBox{}
+	if cfg, err := LoadConfig(dir); err == nil && cfg.Driver != "" {
+		driver, _ = machine.NewDriver(cfg.Driver)
+		ok = true
+	}
+	return filepath.Base(dir), driver, ok
+}
+
+// DetectDriver returns the driver of the workbench in dir, or in its parent when dir is an app
+// directory, defaulting to VirtualBox
+func DetectDriver(dir string) machine.Driver {
+	driver, _ := FindDriver(dir)
+	return driver
+}
+
+// FindDriver is like DetectDriver, but also reports whether dir is in a workbench, i.e. whether
+// it or its parent has a machine record or sets a driver in .workbench.yml
+func FindDriver(dir string) (machine.Driver, bool) {
+	for _, d := range []string{dir, filepath.Dir(dir)} {
+		if _, driver, ok := identify(d); ok {
+			return driver, true
+		}
 	}
+	return machine.VirtualBox{}, false
+}
+
+// SetDriver changes the driver a new workbench machine will be created with
+func (w *Workbench) SetDriver(d machine.Driver) {
+	w.Driver = d
+	w.Defaults = w.Config.MachineDefaults(d.Vars())
 }
 
-// StartProxy will start a reverse proxy on the given IP address and port number for the workbench
-func (w *Workbench) StartProxy(ip, port string) {
-	l, err := net.Listen("tcp4", fmt.Sprintf(":%s", port))
+func (w *Workbench) loadConfig() error {
+	cfg, err := LoadConfig(w.Dir)
+	if err != nil {
+		return err
+	}
+	w.Config = cfg
+	w.SetDriver(w.Driver)
+	return nil
+}
+
+// RunHooks runs each hook script with sh from the workbench directory, stopping at the first that fails
+func (w *Workbench) RunHooks(ctx context.Context, scripts []string) error {
+	r := w.Runner
+	if r == nil {
+		r = run.Default
+	}
+	ctx = run.WithEnv(ctx, "WORKBENCH_NAME="+w.Name, "WORKBENCH_DIR="+w.Dir)
+	for _, script := range scripts {
+		if err := r.Run(ctx, "sh", "-c", "cd "+run.Quote(w.Dir)+" && "+script); err != nil {
+			return fmt.Errorf("docker-workbench: hook '%s' failed: %s", script, err)
+		}
+	}
+	return nil
+}
+
+// PrintWorkbenchInfo prints the application URL using the app name and machine IP of the workbench
+func (w *Workbench) PrintWorkbenchInfo(ctx context.Context) error {
+	ip, err := w.IP(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	proxy := httputil.NewSingleHostReverseProxy(&url.URL{
-		Scheme: "http",
-		Host:   fmt.Sprintf("%s.%s.nip.io", w.App, ip),
-	})
-	log.Fatal(http.Serve(l, proxy))
+	fmt.Println("\nBrowse the workbench using:")
+	fmt.Printf("http://%s.%s.nip.io/\n", w.App, ip)
+	return nil
 }
 
 // GetProxyIPs returns a slice of IP address strings that should be browsable when using the Proxy command
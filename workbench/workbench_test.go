@@ -29,3 +29,20 @@ func TestValidProxyIP_Invalid(t *testing.T) {
 		}
 	}
 }
+
+func TestSetApp(t *testing.T) {
+	w := newTestWorkbench(nil)
+	w.Dir = makeWorkbenchDir(t)
+
+	if err := w.SetApp("myapp"); err != nil || w.App != "myapp" {
+		t.Errorf("unexpected result: %s %v", w.App, err)
+	}
+	for _, app := range []string{"missing", "..", "myapp/docker-compose.yml", "../myapp"} {
+		if err := w.SetApp(app); err == nil {
+			t.Errorf("expected an error for %s", app)
+		}
+	}
+	if w.App != "myapp" {
+		t.Errorf("app was changed to %s", w.App)
+	}
+}